@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mobserver/exporter"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const checkTimeout = 30 * time.Second
+
+// runCheck verifies the privileges of the MongoDB user and prints the collectors to enable or disable.
+// It returns the exit code, non-zero if the check fails or an enabled collector lacks privileges.
+func runCheck(opts *Flags, out io.Writer, log *logrus.Logger) int {
+	exp, err := buildExporter(opts, log)
+	if err != nil {
+		fmt.Fprintf(out, "Invalid options: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+
+	report, err := exp.Check(ctx)
+	if err != nil {
+		fmt.Fprintf(out, "Check failed: %v\n", err)
+		return 1
+	}
+
+	printCheckReport(out, report)
+
+	if report.DetectionError != nil {
+		fmt.Fprintf(out, "\nFAIL: %v\n", report.DetectionError)
+		return 1
+	}
+
+	if !report.OK() {
+		fmt.Fprintln(out, "\nFAIL: some enabled collectors lack privileges")
+		return 1
+	}
+
+	fmt.Fprintln(out, "\nOK")
+	return 0
+}
+
+func printCheckReport(out io.Writer, report *exporter.CheckReport) {
+	if report.AccessControl {
+		fmt.Fprintf(out, "Authenticated users: %s\n", listOrNone(report.Users))
+	} else {
+		fmt.Fprintln(out, "Authenticated users: none, access control is disabled")
+	}
+	fmt.Fprintf(out, "Roles: %s\n\n", listOrNone(report.Roles))
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "COLLECTOR\tSTATE\tPRIVILEGES")
+	detection := "-"
+	if report.DetectionError != nil {
+		detection = "failed"
+	}
+	fmt.Fprintf(w, "(detection)\t%s\t%s\n", detection, privilegesSummary(report.Detection))
+
+	for _, c := range report.Collectors {
+		state := "enabled"
+		if !c.Enabled {
+			state = "disabled: " + c.Reason
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.Name, state, privilegesSummary(c.Privileges))
+	}
+
+	_ = w.Flush()
+}
+
+// privilegesSummary lists the privileges marked as granted (ok) or not (missing).
+func privilegesSummary(checks []exporter.PrivilegeCheck) string {
+	if len(checks) == 0 {
+		return "none required"
+	}
+
+	res := make([]string, 0, len(checks))
+	for _, c := range checks {
+		if c.Granted {
+			res = append(res, c.String()+": ok")
+		} else {
+			res = append(res, c.String()+": MISSING")
+		}
+	}
+
+	return strings.Join(res, ", ")
+}
+
+func listOrNone(list []string) string {
+	if len(list) == 0 {
+		return "none"
+	}
+
+	return strings.Join(list, ", ")
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"mobserver/internal/mongoutils"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+const (
+	credentialFilesCheckInterval = 10 * time.Second
+	redacted                     = "xxxxx"
+	// minSecretLength is the length under which a secret is not redacted from the log text, since a short
+	// secret such as a word would replace unrelated text and hint at the secret. The passwords of URIs
+	// and the password fields are redacted whatever their length.
+	minSecretLength = 8
+)
+
+// readCredentialFiles replaces the URI and the password of the options with the contents of their files.
+func readCredentialFiles(opts *Flags) error {
+	if opts.URIFile != "" {
+		content, err := os.ReadFile(opts.URIFile)
+		if err != nil {
+			return fmt.Errorf("cannot read URI file: %w", err)
+		}
+		opts.URI = strings.TrimSpace(string(content))
+	}
+
+	if opts.PasswordFile != "" {
+		content, err := os.ReadFile(opts.PasswordFile)
+		if err != nil {
+			return fmt.Errorf("cannot read password file: %w", err)
+		}
+		// Only the line break is trimmed, since spaces may be part of the password.
+		opts.Password = strings.TrimRight(string(content), "\r\n")
+	}
+
+	return nil
+}
+
+// credentialFiles returns the credential files of the options.
+func credentialFiles(opts *Flags) []string {
+	var files []string
+	for _, path := range []string{opts.URIFile, opts.PasswordFile} {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
+
+// watchCredentialFiles calls reload whenever the contents of the files change, until ctx is done.
+// The contents are compared rather than the modification times, since secrets mounted by Kubernetes
+// are replaced by swapping symlinks. A failed reload is retried on the next check.
+func watchCredentialFiles(ctx context.Context, files []string, reload func() error, log *logrus.Logger) {
+	last := hashFiles(files, log)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(credentialFilesCheckInterval):
+		}
+
+		current := hashFiles(files, log)
+		if current == nil || bytes.Equal(current, last) {
+			continue
+		}
+
+		log.Infof("Credential files changed, reloading")
+		if err := reload(); err == nil {
+			last = current
+		}
+	}
+}
+
+// hashFiles returns the hash of the contents of the files, or nil if one cannot be read,
+// e.g. in the middle of a rotation.
+func hashFiles(files []string, log *logrus.Logger) []byte {
+	h := sha256.New()
+
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			log.Errorf("Cannot read credential file: %v", err)
+			return nil
+		}
+		h.Write(content)
+		h.Write([]byte{0})
+	}
+
+	return h.Sum(nil)
+}
+
+// redactHook replaces the secrets in the message and the fields of every log entry. The secrets
+// are replaced as whole tokens, not within words.
+type redactHook struct {
+	lock    sync.RWMutex
+	secrets []string
+}
+
+func (h *redactHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *redactHook) Fire(entry *logrus.Entry) error {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+
+	entry.Message = h.redact(entry.Message)
+
+	// The fields are copied for every entry, so they are replaced in place.
+	for key, value := range entry.Data {
+		if strings.Contains(strings.ToLower(key), "password") {
+			entry.Data[key] = redacted
+			continue
+		}
+
+		switch v := value.(type) {
+		case string:
+			entry.Data[key] = h.redact(v)
+		case error:
+			if msg, redactedMsg := v.Error(), h.redact(v.Error()); redactedMsg != msg {
+				entry.Data[key] = redactedMsg
+			}
+		}
+	}
+
+	return nil
+}
+
+// redact replaces the passwords of the URIs and the secrets in s. h.lock must be held.
+func (h *redactHook) redact(s string) string {
+	s = mongoutils.RedactURI(s)
+	for _, secret := range h.secrets {
+		s = replaceToken(s, secret, redacted)
+	}
+
+	return s
+}
+
+// replaceToken replaces the occurrences of token in s which are not part of a longer word,
+// i.e. which are not preceded or followed by a letter or a digit adjacent to a letter or a digit of token.
+func replaceToken(s, token, replacement string) string {
+	var b strings.Builder
+	last := 0
+	for start := 0; start < len(s); {
+		i := strings.Index(s[start:], token)
+		if i < 0 {
+			break
+		}
+		i += start
+		end := i + len(token)
+
+		if tokenBoundary(s[:i], token, false) && tokenBoundary(s[end:], token, true) {
+			b.WriteString(s[last:i])
+			b.WriteString(replacement)
+			last = end
+			start = end
+		} else {
+			start = i + 1
+		}
+	}
+	b.WriteString(s[last:])
+
+	return b.String()
+}
+
+// tokenBoundary reports whether the text before (or after if next is set) the token does not continue a word of it.
+func tokenBoundary(text, token string, next bool) bool {
+	var adjacent, edge rune
+	if next {
+		adjacent, _ = utf8.DecodeRuneInString(text)
+		edge, _ = utf8.DecodeLastRuneInString(token)
+	} else {
+		adjacent, _ = utf8.DecodeLastRuneInString(text)
+		edge, _ = utf8.DecodeRuneInString(token)
+	}
+
+	return text == "" || !isWordRune(adjacent) || !isWordRune(edge)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// setSecrets replaces the secrets to redact with the ones of the options, as they are and URL-encoded.
+func (h *redactHook) setSecrets(opts *Flags) {
+	var secrets []string
+	add := func(secret string) {
+		if len(secret) < minSecretLength {
+			return
+		}
+		secrets = append(secrets, secret)
+		if escaped := url.QueryEscape(secret); escaped != secret {
+			secrets = append(secrets, escaped)
+		}
+	}
+
+	add(opts.Password)
+	add(opts.TLSKeyPassword)
+	if cs, err := connstring.Parse(opts.URI); err == nil {
+		add(cs.Password)
+	}
+	// The password as escaped in the URI.
+	add(mongoutils.URIPassword(opts.URI))
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.secrets = secrets
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/sirupsen/logrus"
+)
+
+// runDump collects the enabled collectors once and writes the metrics to out in the format.
+// It returns the exit code, non-zero if MongoDB cannot be reached or a collector failed.
+func runDump(opts *Flags, out io.Writer, log *logrus.Logger) int {
+	exp, err := buildExporter(opts, log)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid options: %v\n", err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.Dump.Timeout)
+	defer cancel()
+
+	families, failed, err := exp.Gather(ctx, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Dump failed: %v\n", err)
+		return 1
+	}
+
+	if err := writeMetrics(out, opts.Dump.Format, families); err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot write metrics: %v\n", err)
+		return 1
+	}
+
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Failed collectors: %s\n", strings.Join(failed, ", "))
+		return 1
+	}
+
+	return 0
+}
+
+func writeMetrics(out io.Writer, format string, families []*dto.MetricFamily) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(jsonFamilies(families))
+	case "openmetrics":
+		return encodeMetrics(expfmt.NewEncoder(out, expfmt.FmtOpenMetrics_1_0_0), families)
+	default:
+		return encodeMetrics(expfmt.NewEncoder(out, expfmt.FmtText), families)
+	}
+}
+
+func encodeMetrics(enc expfmt.Encoder, families []*dto.MetricFamily) error {
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+
+	// OpenMetrics ends with # EOF.
+	if closer, ok := enc.(expfmt.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+// jsonMetric is a sample of a counter, gauge or untyped metric, or the count and sum of a summary
+// or histogram. Values which are not finite are omitted, since JSON has no NaN or Inf.
+type jsonMetric struct {
+	Labels map[string]string `json:"labels"`
+	Value  *float64          `json:"value,omitempty"`
+	Count  *uint64           `json:"count,omitempty"`
+	Sum    *float64          `json:"sum,omitempty"`
+}
+
+func jsonFamilies(families []*dto.MetricFamily) []jsonFamily {
+	res := make([]jsonFamily, 0, len(families))
+
+	for _, mf := range families {
+		f := jsonFamily{
+			Name:    mf.GetName(),
+			Help:    mf.GetHelp(),
+			Type:    strings.ToLower(mf.GetType().String()),
+			Metrics: make([]jsonMetric, 0, len(mf.Metric)),
+		}
+
+		for _, m := range mf.Metric {
+			jm := jsonMetric{Labels: make(map[string]string, len(m.Label))}
+			for _, l := range m.Label {
+				jm.Labels[l.GetName()] = l.GetValue()
+			}
+
+			switch {
+			case m.Counter != nil:
+				jm.Value = finite(m.Counter.GetValue())
+			case m.Gauge != nil:
+				jm.Value = finite(m.Gauge.GetValue())
+			case m.Untyped != nil:
+				jm.Value = finite(m.Untyped.GetValue())
+			case m.Summary != nil:
+				jm.Count = m.Summary.SampleCount
+				jm.Sum = finite(m.Summary.GetSampleSum())
+			case m.Histogram != nil:
+				jm.Count = m.Histogram.SampleCount
+				jm.Sum = finite(m.Histogram.GetSampleSum())
+			}
+
+			f.Metrics = append(f.Metrics, jm)
+		}
+
+		res = append(res, f)
+	}
+
+	return res
+}
+
+func finite(v float64) *float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return nil
+	}
+
+	return &v
+}
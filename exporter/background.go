@@ -0,0 +1,252 @@
+package exporter
+
+import (
+	"context"
+	"mobserver/internal/metric"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sirupsen/logrus"
+)
+
+const defaultCollectInterval = 30 * time.Second
+
+// collectorCache keeps the metrics of the last completed collection of a collector running
+// in the background, and the result of the last collection whether it succeeded or not.
+type collectorCache struct {
+	lock      sync.Mutex
+	metrics   []prometheus.Metric
+	updatedAt time.Time
+	result    *collectResult
+}
+
+func (c *collectorCache) update(metrics []prometheus.Metric, res collectResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.result = &res
+
+	// Keep serving the last completed metrics when the collection failed.
+	if res.err != nil {
+		return
+	}
+
+	c.metrics = metrics
+	c.updatedAt = time.Now()
+}
+
+func (c *collectorCache) get() ([]prometheus.Metric, time.Time, *collectResult) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.metrics, c.updatedAt, c.result
+}
+
+// StartBackgroundCollection runs every enabled collector on its own interval until ctx is done
+// or the collection is restarted. Once it is started, Handler serves the last completed results
+// instead of collecting on every scrape.
+func (e *Exporter) StartBackgroundCollection(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	opts := e.getOpts()
+	caches := make(map[string]*collectorCache)
+
+	for _, spec := range enabledCollectors(opts, nil) {
+		cache := &collectorCache{}
+		caches[spec.name] = cache
+
+		go e.runInBackground(ctx, spec, cache, collectInterval(opts, spec.name))
+	}
+
+	for name := range opts.CollectIntervals {
+		if _, ok := caches[name]; !ok {
+			e.logger.Warnf("Collect interval is set for %s, but it is not an enabled collector", name)
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.stopBackground != nil {
+		e.stopBackground()
+	}
+	e.caches = caches
+	e.stopBackground = cancel
+}
+
+// StopBackgroundCollection stops the background collection, and Handler collects on every scrape again.
+func (e *Exporter) StopBackgroundCollection() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.stopBackground != nil {
+		e.stopBackground()
+	}
+	e.caches = nil
+	e.stopBackground = nil
+}
+
+func collectInterval(opts *Opts, name string) time.Duration {
+	if interval, ok := opts.CollectIntervals[name]; ok && interval > 0 {
+		return interval
+	}
+
+	if opts.CollectInterval > 0 {
+		return opts.CollectInterval
+	}
+
+	return defaultCollectInterval
+}
+
+func (e *Exporter) runInBackground(ctx context.Context, spec *collectorSpec, cache *collectorCache, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		e.refreshCache(ctx, spec, cache, interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (e *Exporter) refreshCache(ctx context.Context, spec *collectorSpec, cache *collectorCache, timeout time.Duration) {
+	// A collection must not run over into the next one.
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client, err := e.getClient(ctx)
+	if err != nil {
+		e.logger.Errorf("Cannot connect to MongoDB for %s collector: %v", spec.name, err)
+		res := collectResult{err: &unavailableError{class: "connection", err: err}}
+		e.observeResult(spec.name, res)
+		cache.update(nil, res)
+		return
+	}
+
+	if !e.getOpts().GlobalConnPool {
+		defer func() {
+			if err := client.Disconnect(ctx); err != nil {
+				e.logger.Errorf("Cannot disconnect client: %v", err)
+			}
+		}()
+	}
+
+	opts, err := e.detect(ctx, client)
+	if err != nil {
+		e.logger.Errorf("Cannot detect MongoDB server for %s collector: %v", spec.name, err)
+		res := collectResult{err: &unavailableError{class: "detection", err: err}}
+		e.observeResult(spec.name, res)
+		cache.update(nil, res)
+		return
+	}
+
+	if !opts.enabled[spec.name] {
+		// Disabled by the topology of the server.
+		return
+	}
+
+	base := e.newBase(ctx, spec.name, client, opts)
+	metrics := collectOnce(spec.build(opts, base))
+
+	res := base.result()
+	e.observeResult(spec.name, res)
+	cache.update(metrics, res)
+}
+
+// serveCaches serves the cached results of the collectors with the labels, and their staleness.
+func (e *Exporter) serveCaches(w http.ResponseWriter, r *http.Request, caches map[string]*collectorCache, specs []*collectorSpec,
+	labels prometheus.Labels) {
+	registry := prometheus.NewRegistry()
+	selected := make(map[string]*collectorCache)
+
+	for _, spec := range specs {
+		cache, ok := caches[spec.name]
+		if !ok {
+			continue
+		}
+		selected[spec.name] = cache
+
+		if metrics, _, _ := cache.get(); len(metrics) > 0 {
+			registerWithLabels(registry, labels, cachedMetrics(metrics), e.logger)
+		}
+	}
+
+	registry.MustRegister(newCacheCollector(e.logger, selected))
+	registry.MustRegister(e.collectorErrors)
+	registry.MustRegister(e.poolMetrics)
+	registry.MustRegister(newCertMetrics(e.getOpts()))
+
+	h := promhttp.HandlerFor(registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+		ErrorLog:      e.logger,
+	})
+
+	h.ServeHTTP(w, r)
+}
+
+// cachedMetrics emits the metrics of the last completed collection of a collector.
+type cachedMetrics []prometheus.Metric
+
+func (c cachedMetrics) Describe(ch chan<- *prometheus.Desc) {
+	prometheus.DescribeByCollect(c, ch)
+}
+
+func (c cachedMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, mt := range c {
+		ch <- mt
+	}
+}
+
+// cacheCollector emits the results and the staleness of the collectors running in the background.
+type cacheCollector struct {
+	base   *baseCollector
+	caches map[string]*collectorCache
+}
+
+func newCacheCollector(logger *logrus.Logger, caches map[string]*collectorCache) prometheus.Collector {
+	return &cacheCollector{
+		base:   newBaseCollector(context.Background(), "", nil, logger),
+		caches: caches,
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *cacheCollector) collect(ch chan<- prometheus.Metric) error {
+	now := time.Now()
+	staleness := make(map[string]float64)
+
+	for name, cache := range c.caches {
+		_, updatedAt, res := cache.get()
+		if res != nil {
+			for _, mt := range metric.CollectorResultToPromMetrics(name, res.duration.Seconds(), res.err == nil) {
+				ch <- mt
+			}
+		}
+
+		if updatedAt.IsZero() {
+			// Not completed yet.
+			continue
+		}
+
+		staleness[name] = now.Sub(updatedAt).Seconds()
+	}
+
+	for _, mt := range metric.CollectorStalenessToPromMetrics(staleness) {
+		ch <- mt
+	}
+
+	return nil
+}
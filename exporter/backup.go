@@ -0,0 +1,137 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"mobserver/internal/metric"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// backupCollector inspects the backup artifacts in the backup directory, such as mongodump archives
+// and mounted snapshots, so that a backup which has not succeeded for a while can be alerted on.
+type backupCollector struct {
+	ctx  context.Context
+	base *baseCollector
+
+	backupDir string
+	marker    string
+}
+
+func newBackupCollector(base *baseCollector, backupDir, marker string) prometheus.Collector {
+	return &backupCollector{
+		ctx:  base.ctx,
+		base: base,
+
+		backupDir: backupDir,
+		marker:    marker,
+	}
+}
+
+func (c *backupCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *backupCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *backupCollector) collect(ch chan<- prometheus.Metric) error {
+	status, err := c.getBackupStatus()
+	if err != nil {
+		c.base.logger.Errorf("Failed to get backup status: %v", err)
+		return err
+	}
+
+	for _, mt := range status.ToPromMetrics() {
+		ch <- mt
+	}
+
+	return nil
+}
+
+// getBackupStatus walks the backup directory. The newest backup is the most recently modified entry
+// of the directory, and the marker is looked for in the directory and in the newest backup.
+func (c *backupCollector) getBackupStatus() (*metric.BackupStatus, error) {
+	entries, err := os.ReadDir(c.backupDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	status := &metric.BackupStatus{Dir: c.backupDir, Marker: c.marker}
+
+	var newest time.Time
+	newestEntry := ""
+
+	for _, entry := range entries {
+		path := filepath.Join(c.backupDir, entry.Name())
+
+		modTime, err := c.walk(path, status)
+		if err != nil {
+			return nil, err
+		}
+		if modTime.After(newest) {
+			newest = modTime
+			newestEntry = path
+		}
+	}
+
+	status.NewestAge = math.NaN()
+	if !newest.IsZero() {
+		status.NewestAge = time.Since(newest).Seconds()
+	}
+
+	if c.marker != "" {
+		status.MarkerPresent = fileExists(filepath.Join(c.backupDir, c.marker))
+		if !status.MarkerPresent && newestEntry != "" {
+			status.MarkerPresent = fileExists(filepath.Join(newestEntry, c.marker))
+		}
+	}
+
+	return status, nil
+}
+
+// walk adds the regular files under path to the status, and returns the latest modification time of them.
+// The files which cannot be read are skipped, since a backup may be in progress.
+func (c *backupCollector) walk(path string, status *metric.BackupStatus) (time.Time, error) {
+	var latest time.Time
+
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if ctxErr := c.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			c.base.logger.Warnf("Cannot read %s: %v", p, err)
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			c.base.logger.Warnf("Cannot stat %s: %v", p, err)
+			return nil
+		}
+
+		status.Files++
+		status.Size += float64(info.Size())
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+
+		return nil
+	})
+
+	return latest, err
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
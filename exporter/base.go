@@ -1,45 +1,94 @@
 package exporter
 
 import (
+	"context"
+	"errors"
+	"mobserver/internal/metric"
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson/bsoncodec"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/auth"
 )
 
 const defaultCacheSize = 1000
 
+const (
+	unauthorizedCode         = 13
+	authenticationFailedCode = 18
+)
+
+// collectResult is the result of the last collection of a collector.
+type collectResult struct {
+	duration time.Duration
+	err      error
+}
+
 type baseCollector struct {
+	// ctx bounds every command of the collector, usually by the scrape timeout.
+	ctx    context.Context
+	name   string
 	client *mongo.Client
 	logger *logrus.Logger
 
+	// nsLimit is the number of namespaces the collector exports separately, unlimited if it is 0 or less.
+	nsLimit   int
+	nsLimiter *metric.NamespaceLimiter
+	// replEvents is shared by the collections of the exporter.
+	replEvents *metric.ReplEventTracker
+
 	lock         sync.Mutex
+	collected    bool
 	metricsCache []prometheus.Metric
+	lastResult   collectResult
 }
 
-func newBaseCollector(client *mongo.Client, logger *logrus.Logger) *baseCollector {
+func newBaseCollector(ctx context.Context, name string, client *mongo.Client, logger *logrus.Logger) *baseCollector {
 	return &baseCollector{
+		ctx:    ctx,
+		name:   name,
 		client: client,
 		logger: logger,
 	}
 }
 
-func (d *baseCollector) Describe(ch chan<- *prometheus.Desc, collect func(mCh chan<- prometheus.Metric)) {
+// Describe collects metrics on the first call and describes the collected ones.
+func (d *baseCollector) Describe(ch chan<- *prometheus.Desc, collect func(mCh chan<- prometheus.Metric) error) {
 	d.lock.Lock()
 	defer d.lock.Unlock()
 
+	if !d.collected {
+		d.collect(collect)
+	}
+
+	for _, m := range d.metricsCache {
+		ch <- m.Desc()
+	}
+}
+
+func (d *baseCollector) collect(collect func(mCh chan<- prometheus.Metric) error) {
+	d.collected = true
 	d.metricsCache = make([]prometheus.Metric, 0, defaultCacheSize)
 
+	start := time.Now()
+	var err error
+
 	metrics := make(chan prometheus.Metric)
 	go func() {
-		collect(metrics)
+		err = collect(metrics)
 		close(metrics)
 	}()
 
 	for m := range metrics {
 		d.metricsCache = append(d.metricsCache, m)
-		ch <- m.Desc()
+	}
+
+	d.lastResult = collectResult{
+		duration: time.Since(start),
+		err:      err,
 	}
 }
 
@@ -51,3 +100,104 @@ func (d *baseCollector) Collect(ch chan<- prometheus.Metric) {
 		ch <- metric
 	}
 }
+
+// prefetch runs Describe of the collector, which makes collectors built on baseCollector
+// collect their metrics, so that registering them later does not have to wait for MongoDB.
+func prefetch(c prometheus.Collector) {
+	descs := make(chan *prometheus.Desc)
+	go func() {
+		c.Describe(descs)
+		close(descs)
+	}()
+
+	for range descs {
+	}
+}
+
+// collectOnce runs the collector and returns the metrics it produced.
+func collectOnce(c prometheus.Collector) []prometheus.Metric {
+	prefetch(c)
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(metrics)
+		close(metrics)
+	}()
+
+	res := []prometheus.Metric{}
+	for m := range metrics {
+		res = append(res, m)
+	}
+
+	return res
+}
+
+func (d *baseCollector) result() collectResult {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.lastResult
+}
+
+// roleChangeCodes is the codes of the server errors which come from a change of the role of the server:
+// NotWritablePrimary, NotPrimaryNoSecondaryOk, NotPrimaryOrSecondary, PrimarySteppedDown,
+// ShutdownInProgress, InterruptedDueToReplStateChange, NotYetInitialized and NoReplicationEnabled.
+var roleChangeCodes = []int{10107, 13435, 13436, 189, 91, 11602, 94, 76}
+
+// isRoleChangeError returns whether the error comes from a change of the role of the server.
+func isRoleChangeError(err error) bool {
+	var serverErr mongo.ServerError
+	if !errors.As(err, &serverErr) {
+		return false
+	}
+
+	for _, code := range roleChangeCodes {
+		if serverErr.HasErrorCode(code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// unavailableError is the error of the collectors which are not run, because MongoDB cannot be
+// connected to or detected. class is connection or detection.
+type unavailableError struct {
+	class string
+	err   error
+}
+
+func (e *unavailableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unavailableError) Unwrap() error {
+	return e.err
+}
+
+// errorClass classifies the error of a collector into auth, timeout, network, decode, connection,
+// detection or other.
+func errorClass(err error) string {
+	var unavailable *unavailableError
+	var serverErr mongo.ServerError
+	var authErr *auth.Error
+	var decodeErr *bsoncodec.DecodeError
+	var valueDecoderErr bsoncodec.ValueDecoderError
+
+	switch {
+	case errors.As(err, &unavailable):
+		return unavailable.class
+	case errors.As(err, &authErr):
+		return "auth"
+	case errors.As(err, &serverErr) && (serverErr.HasErrorCode(unauthorizedCode) || serverErr.HasErrorCode(authenticationFailedCode)):
+		return "auth"
+	case mongo.IsTimeout(err) || errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case mongo.IsNetworkError(err):
+		return "network"
+	case errors.As(err, &decodeErr) || errors.As(err, &valueDecoderErr):
+		return "decode"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,232 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
+	"strings"
+)
+
+// detectionPrivileges is the privileges needed to detect the server, whichever collectors are enabled.
+var detectionPrivileges = []Privilege{ClusterPrivilege("getCmdLineOpts")}
+
+// Privilege is an action on a resource, as granted by the roles of MongoDB.
+type Privilege struct {
+	// Cluster is set for the actions on the cluster.
+	Cluster bool
+	// DB is the database of the resource, any database if it is empty.
+	DB string
+	// Collection is the collection of the resource, the database itself if it is empty.
+	Collection string
+	Action     string
+}
+
+// ClusterPrivilege returns the privilege of the action on the cluster, e.g. serverStatus.
+func ClusterPrivilege(action string) Privilege {
+	return Privilege{Cluster: true, Action: action}
+}
+
+// DatabasePrivilege returns the privilege of the action on the database, or on any database if db is empty.
+func DatabasePrivilege(db, action string) Privilege {
+	return Privilege{DB: db, Action: action}
+}
+
+// CollectionPrivilege returns the privilege of the action on the collection of the database.
+func CollectionPrivilege(db, collection, action string) Privilege {
+	return Privilege{DB: db, Collection: collection, Action: action}
+}
+
+func (p Privilege) String() string {
+	switch {
+	case p.Cluster:
+		return p.Action + " on cluster"
+	case p.DB == "":
+		return p.Action + " on any database"
+	case p.Collection == "":
+		return p.Action + " on " + p.DB
+	default:
+		return p.Action + " on " + p.DB + "." + p.Collection
+	}
+}
+
+// grantedBy reports whether the privilege is granted by the privilege of connectionStatus.
+func (p Privilege) grantedBy(granted *model.PrivilegeDoc) bool {
+	hasAction := false
+	for _, action := range granted.Actions {
+		if action == p.Action || action == "anyAction" {
+			hasAction = true
+			break
+		}
+	}
+	if !hasAction {
+		return false
+	}
+
+	resource := granted.Resource
+
+	switch {
+	case resource.AnyResource:
+		return true
+	case p.Cluster || resource.Cluster:
+		return p.Cluster && resource.Cluster
+	case resource.DB != "" && resource.DB != p.DB:
+		return false
+	case resource.Collection != "":
+		return resource.Collection == p.Collection
+	case strings.HasPrefix(p.Collection, "system."):
+		// A resource of databases covers only their normal collections.
+		return false
+	default:
+		// The resource of every database does not cover local and config.
+		return resource.DB != "" || (p.DB != "local" && p.DB != "config")
+	}
+}
+
+// PrivilegeCheck is a required privilege and whether the authenticated users have it.
+type PrivilegeCheck struct {
+	Privilege
+	Granted bool
+}
+
+// CollectorCheck is the plan of a registered collector.
+type CollectorCheck struct {
+	Name    string
+	Enabled bool
+	// Reason is why the collector is disabled.
+	Reason     string
+	Privileges []PrivilegeCheck
+}
+
+// Missing returns the required privileges which are not granted.
+func (c *CollectorCheck) Missing() []Privilege {
+	missing := []Privilege{}
+	for _, p := range c.Privileges {
+		if !p.Granted {
+			missing = append(missing, p.Privilege)
+		}
+	}
+
+	return missing
+}
+
+// CheckReport is the result of Exporter.Check.
+type CheckReport struct {
+	// Users is the authenticated users in the form user@db, and Roles their roles in the form role@db.
+	Users []string
+	Roles []string
+	// AccessControl is false if the server was detected without authenticated users, in which case
+	// access control is disabled and every privilege is granted.
+	AccessControl bool
+	// Detection is the privileges needed to detect the server.
+	Detection []PrivilegeCheck
+	// DetectionError is why the server cannot be detected, nil if it is detected. The collectors are
+	// then planned by the options only.
+	DetectionError error
+	// Collectors is every registered collector in the order of registration.
+	Collectors []CollectorCheck
+}
+
+// OK reports whether the server can be detected and every enabled collector has its privileges.
+func (r *CheckReport) OK() bool {
+	if r.DetectionError != nil {
+		return false
+	}
+
+	for _, p := range r.Detection {
+		if !p.Granted {
+			return false
+		}
+	}
+
+	for i := range r.Collectors {
+		if r.Collectors[i].Enabled && len(r.Collectors[i].Missing()) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check connects to MongoDB, verifies the privileges of the authenticated users with connectionStatus
+// and detects the server, returning the collectors the exporter would enable or disable and why.
+// The privileges are verified before the detection, which needs some of them, so that a failed
+// detection is reported with them. It returns an error only if MongoDB cannot be reached.
+func (e *Exporter) Check(ctx context.Context) (*CheckReport, error) {
+	client, err := e.getClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := e.getOpts()
+	if !opts.GlobalConnPool {
+		defer disconnect(client, opts)
+	}
+
+	status, err := mongoutils.GetConnectionStatus(ctx, client)
+	if err != nil {
+		return nil, err
+	}
+
+	// getCmdLineOpts of the detection fails without authenticated users if access control is enabled.
+	accessControl := len(status.AuthInfo.AuthenticatedUsers) > 0
+
+	report := &CheckReport{
+		Users:         []string{},
+		Roles:         []string{},
+		AccessControl: accessControl,
+		Detection:     checkPrivileges(status, accessControl, detectionPrivileges),
+	}
+
+	topology, reasons, err := detectTopology(ctx, client, opts)
+	if err != nil {
+		report.DetectionError = fmt.Errorf("cannot detect MongoDB server: %w", err)
+		topology = opts
+	}
+
+	for _, user := range status.AuthInfo.AuthenticatedUsers {
+		report.Users = append(report.Users, user.User+"@"+user.DB)
+	}
+	for _, role := range status.AuthInfo.AuthenticatedUserRoles {
+		report.Roles = append(report.Roles, role.Role+"@"+role.DB)
+	}
+
+	for _, spec := range registeredCollectors() {
+		c := CollectorCheck{
+			Name:       spec.name,
+			Enabled:    topology.enabled[spec.name],
+			Privileges: checkPrivileges(status, accessControl, spec.requiredPrivileges),
+		}
+
+		switch {
+		case c.Enabled:
+		case reasons[spec.name] != "":
+			c.Reason = reasons[spec.name]
+		case opts.disabled[spec.name] != "":
+			c.Reason = opts.disabled[spec.name]
+		default:
+			c.Reason = "it is not enabled by the options"
+		}
+
+		report.Collectors = append(report.Collectors, c)
+	}
+
+	return report, nil
+}
+
+func checkPrivileges(status *model.ConnectionStatusDoc, accessControl bool, privileges []Privilege) []PrivilegeCheck {
+	res := []PrivilegeCheck{}
+
+	for _, p := range privileges {
+		check := PrivilegeCheck{Privilege: p, Granted: !accessControl}
+		for i := range status.AuthInfo.AuthenticatedUserPrivileges {
+			if p.grantedBy(&status.AuthInfo.AuthenticatedUserPrivileges[i]) {
+				check.Granted = true
+				break
+			}
+		}
+		res = append(res, check)
+	}
+
+	return res
+}
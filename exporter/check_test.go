@@ -0,0 +1,160 @@
+package exporter
+
+import (
+	"mobserver/internal/model"
+	"testing"
+)
+
+func privilegeDoc(cluster, anyResource bool, db, collection string, actions ...string) *model.PrivilegeDoc {
+	doc := &model.PrivilegeDoc{Actions: actions}
+	doc.Resource.Cluster = cluster
+	doc.Resource.AnyResource = anyResource
+	doc.Resource.DB = db
+	doc.Resource.Collection = collection
+
+	return doc
+}
+
+func TestPrivilegeGrantedBy(t *testing.T) {
+	tests := []struct {
+		name      string
+		privilege Privilege
+		granted   *model.PrivilegeDoc
+		want      bool
+	}{
+		{
+			name:      "cluster action on cluster",
+			privilege: ClusterPrivilege("serverStatus"),
+			granted:   privilegeDoc(true, false, "", "", "top", "serverStatus"),
+			want:      true,
+		},
+		{
+			name:      "other cluster action",
+			privilege: ClusterPrivilege("serverStatus"),
+			granted:   privilegeDoc(true, false, "", "", "top"),
+			want:      false,
+		},
+		{
+			name:      "cluster action on any database",
+			privilege: ClusterPrivilege("serverStatus"),
+			granted:   privilegeDoc(false, false, "", "", "serverStatus"),
+			want:      false,
+		},
+		{
+			name:      "database action on cluster",
+			privilege: DatabasePrivilege("config", "find"),
+			granted:   privilegeDoc(true, false, "", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "any resource",
+			privilege: ClusterPrivilege("serverStatus"),
+			granted:   privilegeDoc(false, true, "", "", "serverStatus"),
+			want:      true,
+		},
+		{
+			name:      "any action on any resource",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, true, "", "", "anyAction"),
+			want:      true,
+		},
+		{
+			name:      "database action on the database",
+			privilege: DatabasePrivilege("config", "find"),
+			granted:   privilegeDoc(false, false, "config", "", "find"),
+			want:      true,
+		},
+		{
+			name:      "database action on another database",
+			privilege: DatabasePrivilege("config", "find"),
+			granted:   privilegeDoc(false, false, "local", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "database action on any database",
+			privilege: DatabasePrivilege("config", "find"),
+			granted:   privilegeDoc(false, false, "", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "database action on any database for a normal database",
+			privilege: DatabasePrivilege("app", "find"),
+			granted:   privilegeDoc(false, false, "", "", "find"),
+			want:      true,
+		},
+		{
+			name:      "action on any database granted on any database",
+			privilege: DatabasePrivilege("", "listCollections"),
+			granted:   privilegeDoc(false, false, "", "", "listCollections"),
+			want:      true,
+		},
+		{
+			name:      "oplog on any database",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, false, "", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "system collection on any database",
+			privilege: CollectionPrivilege("app", "system.profile", "find"),
+			granted:   privilegeDoc(false, false, "", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "system collection on the database",
+			privilege: CollectionPrivilege("app", "system.profile", "find"),
+			granted:   privilegeDoc(false, false, "app", "", "find"),
+			want:      false,
+		},
+		{
+			name:      "system collection on the collection",
+			privilege: CollectionPrivilege("app", "system.profile", "find"),
+			granted:   privilegeDoc(false, false, "app", "system.profile", "find"),
+			want:      true,
+		},
+		{
+			name:      "action on any database granted on one database",
+			privilege: DatabasePrivilege("", "listCollections"),
+			granted:   privilegeDoc(false, false, "config", "", "listCollections"),
+			want:      false,
+		},
+		{
+			name:      "database action on a collection",
+			privilege: DatabasePrivilege("local", "find"),
+			granted:   privilegeDoc(false, false, "local", "oplog.rs", "find"),
+			want:      false,
+		},
+		{
+			name:      "collection action on the collection",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, false, "local", "oplog.rs", "find"),
+			want:      true,
+		},
+		{
+			name:      "collection action on the database",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, false, "local", "", "find"),
+			want:      true,
+		},
+		{
+			name:      "collection action on another collection",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, false, "local", "startup_log", "find"),
+			want:      false,
+		},
+		{
+			name:      "collection action on the collection of any database",
+			privilege: CollectionPrivilege("local", "oplog.rs", "find"),
+			granted:   privilegeDoc(false, false, "", "oplog.rs", "find"),
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.privilege.grantedBy(tt.granted); got != tt.want {
+				t.Errorf("%s granted by %+v = %v, want %v", tt.privilege, *tt.granted, got, tt.want)
+			}
+		})
+	}
+}
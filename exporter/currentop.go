@@ -4,12 +4,11 @@ import (
 	"context"
 	"mobserver/internal/metric"
 	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type currentOpCollector struct {
@@ -17,13 +16,15 @@ type currentOpCollector struct {
 	base *baseCollector
 
 	minQueryTimeMs int
+	nsFilter       *metric.NamespaceFilter
 }
 
-func newCurrentOpCollector(client *mongo.Client, logger *logrus.Logger, minQueryTimeMs int) prometheus.Collector {
+func newCurrentOpCollector(base *baseCollector, minQueryTimeMs int, nsFilter *metric.NamespaceFilter) prometheus.Collector {
 	return &currentOpCollector{
-		ctx:            context.Background(),
-		base:           newBaseCollector(client, logger),
+		ctx:            base.ctx,
+		base:           base,
 		minQueryTimeMs: minQueryTimeMs,
+		nsFilter:       nsFilter,
 	}
 }
 
@@ -35,28 +36,32 @@ func (c *currentOpCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *currentOpCollector) collect(ch chan<- prometheus.Metric) {
+func (c *currentOpCollector) collect(ch chan<- prometheus.Metric) error {
 	rawOps, err := c.getCurrentOp()
 	if err != nil {
-		return
+		return err
 	}
 
-	filter := func(c model.CurrentOpBatchField) bool {
-		if c.Command == nil {
+	filter := func(op model.CurrentOpBatchField) bool {
+		if !c.nsFilter.Match(op.Ns) {
+			return false
+		}
+
+		if op.Command == nil {
 			return true
 		}
 
-		if _, exist := c.Command["createIndexes"]; exist {
+		if _, exist := op.Command["createIndexes"]; exist {
 			return false
 		}
 
-		if tr, ok := c.Command["$truncated"].(string); ok {
+		if tr, ok := op.Command["$truncated"].(string); ok {
 			if strings.HasPrefix(tr, "{ createIndexes") {
 				return false
 			}
 		}
 
-		if strings.Contains(c.Msg, "Index Build") {
+		if strings.Contains(op.Msg, "Index Build") {
 			return false
 		}
 
@@ -75,11 +80,11 @@ func (c *currentOpCollector) collect(ch chan<- prometheus.Metric) {
 	for _, mt := range opWithTotal.ToPromMetrics() {
 		ch <- mt
 	}
+
+	return nil
 }
 
 func (c *currentOpCollector) getCurrentOp() ([]model.CurrentOpBatchField, error) {
-	systemNsRegex := "^$|^admin.*|^local.*|^config.*|^.*system\\.buckets$|^.*system\\.profile$|^.*system\\.js$|^.*system\\.views$"
-
 	currentOp := bson.D{{Key: "$currentOp", Value: bson.D{
 		{Key: "allUsers", Value: true},
 		{Key: "idleConnections", Value: false},
@@ -91,7 +96,8 @@ func (c *currentOpCollector) getCurrentOp() ([]model.CurrentOpBatchField, error)
 
 	matchStage := bson.D{{Key: "$match", Value: bson.D{
 		{Key: "microsecs_running", Value: bson.D{{Key: "$gt", Value: c.minQueryTimeMs * 1000}}},
-		{Key: "ns", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$regex", Value: systemNsRegex}}}}},
+		// The other namespaces are filtered by nsFilter.
+		{Key: "ns", Value: bson.D{{Key: "$ne", Value: ""}}},
 		{Key: "desc", Value: bson.D{{Key: "$regex", Value: "^conn"}}},
 		{Key: "op", Value: bson.D{{Key: "$nin", Value: bson.A{"", "none"}}}},
 	}}}
@@ -119,7 +125,7 @@ func (c *currentOpCollector) getCurrentOp() ([]model.CurrentOpBatchField, error)
 
 	result := model.CurrentOp{}
 
-	if err := c.base.client.Database("admin").RunCommand(c.ctx, cmd).Decode(&result); err != nil {
+	if err := c.base.client.Database("admin").RunCommand(c.ctx, mongoutils.WithMaxTime(c.ctx, cmd)).Decode(&result); err != nil {
 		c.base.logger.Errorf("Failed to get currentOp command: %v", err)
 		return nil, err
 	}
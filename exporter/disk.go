@@ -0,0 +1,169 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"mobserver/internal/metric"
+	"mobserver/internal/mongoutils"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// defaultDBPath is the dbPath of mongod on Linux, which getCmdLineOpts omits unless it is set.
+const defaultDBPath = "/data/db"
+
+// diskCollector reports the usage of the filesystems of the directories of MongoDB on the local host,
+// so that the exhaustion of the disks can be forecast.
+type diskCollector struct {
+	ctx  context.Context
+	base *baseCollector
+
+	isMongos  bool
+	backupDir string
+}
+
+func newDiskCollector(base *baseCollector, isMongos bool, backupDir string) prometheus.Collector {
+	return &diskCollector{
+		ctx:  base.ctx,
+		base: base,
+
+		isMongos:  isMongos,
+		backupDir: backupDir,
+	}
+}
+
+func (c *diskCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *diskCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *diskCollector) collect(ch chan<- prometheus.Metric) error {
+	cmdLineOpts, err := mongoutils.GetCmdLineOpts(c.ctx, c.base.client)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get command line options: %v", err)
+		return err
+	}
+
+	// The directories by kind, in the order of the metrics. mongos has no dbPath.
+	dirs := [][2]string{}
+
+	dbPath := ""
+	if !c.isMongos {
+		dbPath = cmdLineOpts.Parsed.Storage.DBPath
+		if dbPath == "" {
+			dbPath = defaultDBPath
+		}
+		// The journal is often a symbolic link to another disk.
+		dirs = append(dirs, [2]string{"dbpath", dbPath}, [2]string{"journal", filepath.Join(dbPath, "journal")})
+	}
+	if systemLog := cmdLineOpts.Parsed.SystemLog; systemLog.Destination == "file" && systemLog.Path != "" {
+		dirs = append(dirs, [2]string{"log", filepath.Dir(systemLog.Path)})
+	}
+	if c.backupDir != "" {
+		dirs = append(dirs, [2]string{"backup", c.backupDir})
+	}
+
+	var firstErr error
+
+	for _, dir := range dirs {
+		usage, err := diskUsage(dir[0], dir[1])
+		if os.IsNotExist(err) {
+			// e.g. no journal with the in-memory storage engine
+			c.base.logger.Debugf("Skipping %s directory %s: %v", dir[0], dir[1], err)
+			continue
+		} else if err != nil {
+			c.base.logger.Errorf("Failed to get disk usage of %s directory: %v", dir[0], err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		for _, mt := range usage.ToPromMetrics() {
+			ch <- mt
+		}
+	}
+
+	if dbPath != "" {
+		size, err := c.dataFilesSize(dbPath)
+		if err != nil {
+			c.base.logger.Errorf("Failed to get size of data files: %v", err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			for _, mt := range metric.DataFilesToPromMetrics(dbPath, size) {
+				ch <- mt
+			}
+		}
+	}
+
+	return firstErr
+}
+
+func diskUsage(kind, path string) (*metric.DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: path, Err: err}
+	}
+
+	return &metric.DiskUsage{
+		Kind:       kind,
+		Path:       path,
+		Total:      float64(stat.Blocks) * float64(stat.Bsize),
+		Free:       float64(stat.Bavail) * float64(stat.Bsize),
+		Inodes:     float64(stat.Files),
+		InodesFree: float64(stat.Ffree),
+	}, nil
+}
+
+// dataFilesSize returns the total size of the regular files under dbPath, following the symbolic link
+// of the journal. The files removed while walking, e.g. by a checkpoint, are skipped.
+func (c *diskCollector) dataFilesSize(dbPath string) (float64, error) {
+	size := 0.0
+
+	walk := func(path string, d fs.DirEntry, err error) error {
+		if ctxErr := c.ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if os.IsNotExist(err) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		size += float64(info.Size())
+
+		return nil
+	}
+
+	if err := filepath.WalkDir(dbPath, walk); err != nil {
+		return 0, fmt.Errorf("failed to walk %s: %w", dbPath, err)
+	}
+
+	// WalkDir does not follow the symbolic links, so the journal is walked on its own if it is one.
+	journal := filepath.Join(dbPath, "journal")
+	if info, err := os.Lstat(journal); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		if err := filepath.WalkDir(journal+string(filepath.Separator), walk); err != nil {
+			return 0, fmt.Errorf("failed to walk %s: %w", journal, err)
+		}
+	}
+
+	return size, nil
+}
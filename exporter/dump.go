@@ -0,0 +1,46 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Gather collects the enabled collectors once, or the ones named in filters, as Handler does
+// without background collection. It returns the gathered metrics and the sorted names of the
+// collectors which failed. It returns an error if MongoDB cannot be reached or detected.
+func (e *Exporter) Gather(ctx context.Context, filters []string) ([]*dto.MetricFamily, []string, error) {
+	client, err := e.getClient(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	opts := e.getOpts()
+	if !opts.GlobalConnPool {
+		defer disconnect(client, opts)
+	}
+
+	detected, err := e.detect(ctx, client)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot detect MongoDB server: %w", err)
+	}
+
+	registry, results := e.makeRegistry(ctx, client, detected, enabledCollectors(detected, filters), nil)
+
+	families, err := registry.Gather()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot gather metrics: %w", err)
+	}
+
+	failed := []string{}
+	for name, res := range results {
+		if res.err != nil {
+			failed = append(failed, name)
+		}
+	}
+	sort.Strings(failed)
+
+	return families, failed, nil
+}
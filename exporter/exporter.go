@@ -2,10 +2,13 @@ package exporter
 
 import (
 	"context"
+	"fmt"
+	"mobserver/internal/metric"
 	"mobserver/internal/mongoutils"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,13 +19,41 @@ import (
 )
 
 type Exporter struct {
+	// ctx bounds the background work of the exporter, such as the health checks of the connection pools.
+	ctx      context.Context
 	client   *mongo.Client
 	clientMu sync.Mutex
 	logger   *logrus.Logger
-	opts     *Opts
-	lock     *sync.Mutex
-
-	isMongos bool
+	// opts is replaced as a whole on reload, so it must not be modified once the exporter serves.
+	opts *Opts
+	lock *sync.Mutex
+	// topology is opts without the collectors which do not apply to the detected server.
+	// It is nil until the server is detected.
+	topology   *Opts
+	detectedAt time.Time
+	// detectMu serializes the detection of the server.
+	detectMu sync.Mutex
+
+	collectorErrors *prometheus.CounterVec
+	poolMetrics     *connPoolMetrics
+
+	// caches holds the results of the collectors running in the background.
+	caches         map[string]*collectorCache
+	stopBackground context.CancelFunc
+
+	// limiters keeps the activity of the namespaces by the names of the collectors.
+	limiters   map[string]*metric.NamespaceLimiter
+	limitersMu sync.Mutex
+
+	// replEvents keeps the replication state of the previous collection of replevents.
+	replEvents   *metric.ReplEventTracker
+	replEventsMu sync.Mutex
+
+	// target is the host probed by this exporter. It is empty for the main exporter.
+	target    string
+	probeOpts Opts
+	targets   map[string]*probeTarget
+	targetsMu sync.Mutex
 }
 
 type Opts struct {
@@ -33,25 +64,117 @@ type Opts struct {
 	GlobalConnPool   bool
 	TimeoutOffset    int
 
-	CollectAll             bool
+	// TLS enables TLS for the connection. It is implied by the TLS files, and can also be set in URI.
+	TLS bool
+	// TLSCAFile is the PEM file of the certificate authorities to verify the server with.
+	TLSCAFile string
+	// TLSCertFile is the PEM file of the client certificate, and TLSKeyFile of its private key,
+	// which may also be in TLSCertFile. TLSKeyPassword decrypts the key if it is encrypted.
+	TLSCertFile    string
+	TLSKeyFile     string
+	TLSKeyPassword string
+	// TLSAllowInvalidHostnames skips the verification of the hostname of the server certificate.
+	TLSAllowInvalidHostnames bool
+	// AuthMechanism is SCRAM-SHA-256, MONGODB-X509 or PLAIN. The mechanism of URI is used if it is empty.
+	AuthMechanism string
+
+	// PoolCheckInterval is the interval of the health checks of the global connection pool.
+	PoolCheckInterval time.Duration
+	// PoolMaxFailures is the number of consecutive failed health checks to rebuild the global connection pool.
+	PoolMaxFailures int
+	// RedetectInterval is the interval to detect the role of the server again. It is also detected
+	// again after a collection fails with a network error or an error of a change of the role.
+	RedetectInterval time.Duration
+
+	// ProbeAllowedTargets is the targets ProbeHandler may connect to with the credentials of the exporter.
+	// Every other target is rejected, so nothing is probed if it is empty.
+	ProbeAllowedTargets []string
+	// ProbeTargetTTL is the time after which a probe target which is not probed is dropped and disconnected.
+	ProbeTargetTTL time.Duration
+
+	// CollectAll enables all of the registered collectors.
+	CollectAll bool
+	// Collectors is the names of the registered collectors to enable.
+	Collectors []string
+
+	// Deprecated: Add "replicasetstatus" to Collectors instead.
 	EnableReplicasetStatus bool
-	EnableTopMetrics       bool
+	// Deprecated: Add "topmetrics" to Collectors instead.
+	EnableTopMetrics bool
+	// Deprecated: Add "currentopmetrics" to Collectors instead.
 	EnableCurrentopMetrics bool
-	EnableOplogStats       bool
-	EnableShardingStats    bool
+	// Deprecated: Add "oplogstats" to Collectors instead.
+	EnableOplogStats bool
+	// Deprecated: Add "shardstats" to Collectors instead.
+	EnableShardingStats bool
+	// Deprecated: Add "lvmsnapshotstats" to Collectors instead.
 	EnableLVMSnapshotStats bool
-	EnableRollbackStats    bool
-	EnableInstanceMetrics  bool
+	// Deprecated: Add "rollbackstats" to Collectors instead.
+	EnableRollbackStats bool
+	// Deprecated: Add "instance" to Collectors instead.
+	EnableInstanceMetrics bool
 
 	LVMSnapshotBackupDir string
+	// SnapshotBackend is the filesystem of the snapshots of lvmsnapshotstats, one of lvm, zfs and btrfs.
+	// It defaults to lvm.
+	SnapshotBackend string
+	// BackupMarker is the name of the file marking a completed backup for backupstats,
+	// which is looked for in LVMSnapshotBackupDir and in its newest entry.
+	BackupMarker string
+	// SlowQueryThresholdMS is slowOpThresholdMs of the server unless it is set.
 	SlowQueryThresholdMS int
 
+	// BackgroundCollection runs the collectors periodically instead of on every scrape.
+	BackgroundCollection bool
+	CollectInterval      time.Duration
+	// CollectIntervals overrides CollectInterval for the collectors by name.
+	CollectIntervals map[string]time.Duration
+
 	Logger *logrus.Logger
 
 	URI string
+
+	// IncludeDatabases and IncludeCollections restrict the namespaces collected by the per-namespace
+	// collectors to the ones matching the regular expressions, unless they are empty.
+	IncludeDatabases   []string
+	IncludeCollections []string
+	// ExcludeDatabases and ExcludeCollections drop the namespaces matching the regular expressions.
+	// The system databases are excluded if ExcludeDatabases is nil. The system collections are excluded
+	// from currentopmetrics and shardstats if ExcludeCollections is nil, as they were before the filters.
+	ExcludeDatabases   []string
+	ExcludeCollections []string
+
+	// MaxNamespaces limits the namespaces exported separately by each per-namespace collector to the ones
+	// with the highest activity. The others are aggregated into database and collection "__other__".
+	// It is unlimited if it is 0.
+	MaxNamespaces int
+	// CollectorMaxNamespaces overrides MaxNamespaces for the collectors by name.
+	CollectorMaxNamespaces map[string]int
+
+	// IdentityLabels is the names of the labels identifying the server to add to the metrics of the collectors.
+	// They are discovered from the server: replset, host, shard and cluster. None is added if it is empty.
+	IdentityLabels []string
+	// ConstLabels is added to the metrics of the collectors. It takes precedence over IdentityLabels.
+	ConstLabels map[string]string
+
+	isMongos bool
+	// identity is the values of the identity labels by their names.
+	identity map[string]string
+	// enabled is the collectors enabled by the options and left by the validation.
+	enabled map[string]bool
+	// disabled is the reasons of the validation by the names of the collectors it disabled.
+	// It is not modified after the validation, so it is shared by the clones.
+	disabled map[string]string
+	nsFilter *metric.NamespaceFilter
+	// systemNsFilter is nsFilter, which also excludes the system collections if ExcludeCollections is nil.
+	systemNsFilter *metric.NamespaceFilter
 }
 
-func New(opts *Opts) *Exporter {
+// NewWithContext validates opts and creates the exporter without connecting to MongoDB.
+// The server is detected on the first collection, so the exporter can be created before
+// MongoDB is available. ctx bounds the background work such as the health checks of the
+// global connection pool. It returns a *ValidationError for an invalid option.
+func NewWithContext(ctx context.Context, opts *Opts) (*Exporter, error) {
 	if opts == nil {
 		opts = new(Opts)
 	}
@@ -60,40 +183,106 @@ func New(opts *Opts) *Exporter {
 		opts.Logger = logrus.New()
 	}
 
-	ctx := context.Background()
+	probeOpts := *opts
+
+	if err := validateOpts(opts); err != nil {
+		return nil, err
+	}
 
 	exp := &Exporter{
-		logger: opts.Logger,
-		opts:   opts,
-		lock:   &sync.Mutex{},
+		ctx:             ctx,
+		logger:          opts.Logger,
+		opts:            opts,
+		lock:            &sync.Mutex{},
+		collectorErrors: newCollectorErrors(),
+		poolMetrics:     newConnPoolMetrics(),
+		probeOpts:       probeOpts,
+		targets:         make(map[string]*probeTarget),
 	}
 
-	var cli *mongo.Client
-	var err error
+	go exp.checkPoolHealth(ctx)
+	go exp.evictTargets(ctx)
+
+	return exp, nil
+}
+
+// New creates the exporter, waiting until MongoDB answers. It exits the process for invalid options.
+//
+// Deprecated: Use NewWithContext, which neither blocks nor exits.
+func New(opts *Opts) *Exporter {
+	if opts == nil {
+		opts = new(Opts)
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = logrus.New()
+	}
+
+	exp, err := NewWithContext(context.Background(), opts)
+	if err != nil {
+		opts.Logger.Errorf("Failed to validate options: %v", err)
+		os.Exit(1)
+	}
 
 	for {
-		cli, err = exp.getClient(ctx)
-		if err != nil {
-			exp.logger.Errorf("Cannot connect to MongoDB: %v", err)
-			time.Sleep(5 * time.Second)
-		} else {
+		_, err := exp.getClient(context.Background())
+		if err == nil {
 			break
 		}
+
+		exp.logger.Errorf("Cannot connect to MongoDB: %v", err)
+		time.Sleep(5 * time.Second)
+	}
+
+	return exp
+}
+
+// clone returns a copy of the options, which can be modified without affecting the original.
+func (o *Opts) clone() *Opts {
+	c := *o
+	c.enabled = make(map[string]bool, len(o.enabled))
+	for name, enabled := range o.enabled {
+		c.enabled[name] = enabled
+	}
+
+	return &c
+}
+
+// detectServer checks the role of the connected server, its identity and its slow query threshold.
+func detectServer(ctx context.Context, client *mongo.Client, opts *Opts) {
+	hello, err := mongoutils.GetHello(ctx, client)
+	if err == nil {
+		opts.isMongos = hello.Msg == "isdbgrid"
 	}
 
-	if hello, err := mongoutils.GetHello(ctx, cli); err == nil {
-		exp.isMongos = hello.Msg == "isdbgrid"
+	cmdLineOpts, cmdLineErr := mongoutils.GetCmdLineOpts(ctx, client)
+
+	if hello != nil {
+		opts.identity = detectIdentity(ctx, client, opts.Logger, hello, cmdLineOpts)
 	}
-	if cliOpts, err := mongoutils.GetCmdLineOpts(ctx, cli); err != nil {
-		exp.logger.Errorf("Cannot get command line options using default slow query threshold(100ms): %v", err)
-		exp.opts.SlowQueryThresholdMS = 100
+
+	if opts.SlowQueryThresholdMS > 0 {
+		return
+	}
+
+	if cmdLineErr != nil {
+		opts.Logger.Errorf("Cannot get command line options using default slow query threshold(100ms): %v", cmdLineErr)
+		opts.SlowQueryThresholdMS = 100
 	} else {
-		exp.opts.SlowQueryThresholdMS = cliOpts.Parsed.OperationProfiling.SlowOpThresholdMs
+		opts.SlowQueryThresholdMS = cmdLineOpts.Parsed.OperationProfiling.SlowOpThresholdMs
 	}
+}
 
-	return exp
+func (e *Exporter) getOpts() *Opts {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	return e.opts
 }
 
+// ValidateAndModifyOpts detects the server and validates the topology. It exits the process on failure.
+//
+// Deprecated: The server is detected on the first collection of an exporter created by NewWithContext.
 func (e *Exporter) ValidateAndModifyOpts() {
 	ctx := context.TODO()
 	client, err := e.getClient(ctx)
@@ -102,149 +291,263 @@ func (e *Exporter) ValidateAndModifyOpts() {
 		os.Exit(1)
 	}
 
-	if e.opts.CollectAll {
-		e.opts.EnableReplicasetStatus = true
-		e.opts.EnableTopMetrics = true
-		e.opts.EnableCurrentopMetrics = true
-		e.opts.EnableOplogStats = true
-		e.opts.EnableShardingStats = true
-		e.opts.EnableLVMSnapshotStats = true
-		e.opts.EnableRollbackStats = true
-		e.opts.EnableInstanceMetrics = true
-	}
-
-	if err := validateOpts(ctx, client, e.opts); err != nil {
+	if _, err := e.detect(ctx, client); err != nil {
 		e.logger.Errorf("Failed to validate options: %v", err)
 		os.Exit(1)
 	}
 }
 
-func (e *Exporter) makeRegistry(client *mongo.Client) *prometheus.Registry {
-	registry := prometheus.NewRegistry()
-	if client == nil {
-		return registry
+// resolveCollectors enables the registered collectors named in opts, or all of them with CollectAll.
+func resolveCollectors(opts *Opts) error {
+	opts.enabled = make(map[string]bool)
+	opts.disabled = make(map[string]string)
+
+	if opts.CollectAll {
+		for _, spec := range registeredCollectors() {
+			opts.enabled[spec.name] = true
+		}
+		return nil
 	}
 
-	if e.opts.EnableReplicasetStatus {
-		registry.MustRegister(newReplicationStatusCollector(client, e.logger, e.isMongos))
+	for _, name := range append(deprecatedCollectors(opts), opts.Collectors...) {
+		if lookupCollector(name) == nil {
+			return &ValidationError{
+				Option: "Collectors",
+				Value:  name,
+				Err:    fmt.Errorf("%w, must be one of %s", ErrUnknownCollector, strings.Join(CollectorNames(), ", ")),
+			}
+		}
+		opts.enabled[name] = true
 	}
 
-	if e.opts.EnableTopMetrics {
-		registry.MustRegister(newTopCollector(client, e.logger))
+	return nil
+}
+
+// deprecatedCollectors returns the names of the collectors enabled by the deprecated Enable options.
+func deprecatedCollectors(opts *Opts) []string {
+	var names []string
+	for _, c := range []struct {
+		enabled bool
+		name    string
+	}{
+		{opts.EnableReplicasetStatus, "replicasetstatus"},
+		{opts.EnableTopMetrics, "topmetrics"},
+		{opts.EnableCurrentopMetrics, "currentopmetrics"},
+		{opts.EnableOplogStats, "oplogstats"},
+		{opts.EnableShardingStats, "shardstats"},
+		{opts.EnableLVMSnapshotStats, "lvmsnapshotstats"},
+		{opts.EnableRollbackStats, "rollbackstats"},
+		{opts.EnableInstanceMetrics, "instance"},
+	} {
+		if c.enabled {
+			names = append(names, c.name)
+		}
 	}
 
-	if e.opts.EnableCurrentopMetrics {
-		registry.MustRegister(newCurrentOpCollector(client, e.logger, e.opts.SlowQueryThresholdMS))
+	return names
+}
+
+// disableCollector disables the collector if it is enabled, logging the reason.
+func disableCollector(opts *Opts, name string, reason string) {
+	if !opts.enabled[name] {
+		return
 	}
 
-	if e.opts.EnableOplogStats {
-		registry.MustRegister(newOplogCollector(client, e.logger))
+	opts.Logger.Warnf("Disabling %s collector because %s", name, reason)
+	delete(opts.enabled, name)
+	opts.disabled[name] = reason
+}
+
+// enabledCollectors returns the enabled collectors in the order of registration.
+// Only the collectors named in filters are returned, unless filters is empty.
+func enabledCollectors(opts *Opts, filters []string) []*collectorSpec {
+	selected := make(map[string]bool)
+	for _, filter := range filters {
+		// oplogstatus is kept for the scrape configs written before the collector was renamed.
+		if filter == "oplogstatus" {
+			filter = "oplogstats"
+		}
+		selected[filter] = true
 	}
 
-	if e.opts.EnableLVMSnapshotStats {
-		registry.MustRegister(newSnapshotCollector(client, e.logger, e.opts.LVMSnapshotBackupDir))
+	var specs []*collectorSpec
+	for _, spec := range registeredCollectors() {
+		if !opts.enabled[spec.name] {
+			continue
+		}
+		if len(selected) > 0 && !selected[spec.name] {
+			continue
+		}
+		specs = append(specs, spec)
 	}
 
-	if e.opts.EnableRollbackStats {
-		registry.MustRegister(newRollbackCollector(client, e.logger))
+	return specs
+}
+
+// makeRegistry collects the collectors once and registers them, with the results of the collections
+// by the names of the collectors. If unavailable is not nil, the collectors are not run and fail with it,
+// so that an unreachable or undetected server still shows up in the results of every collector.
+func (e *Exporter) makeRegistry(ctx context.Context, client *mongo.Client, opts *Opts,
+	specs []*collectorSpec, unavailable error) (*prometheus.Registry, map[string]collectResult) {
+	registry := prometheus.NewRegistry()
+	results := make(map[string]collectResult)
+
+	// Registered without a client too, since an expired certificate may be why there is none.
+	registry.MustRegister(newCertMetrics(opts))
+	registry.MustRegister(e.collectorErrors)
+	registry.MustRegister(e.poolMetrics)
+
+	if unavailable != nil {
+		for _, spec := range specs {
+			results[spec.name] = collectResult{err: unavailable}
+			e.observeResult(spec.name, results[spec.name])
+		}
+		registry.MustRegister(newResultCollector(e.logger, results))
+
+		return registry, results
 	}
 
-	if e.opts.EnableShardingStats {
-		registry.MustRegister(newShardingStatsCollector(client, e.logger))
+	var names []string
+	var bases []*baseCollector
+	var collectors []prometheus.Collector
+
+	for _, spec := range specs {
+		base := e.newBase(ctx, spec.name, client, opts)
+		names = append(names, spec.name)
+		bases = append(bases, base)
+		collectors = append(collectors, spec.build(opts, base))
 	}
 
-	if e.opts.EnableInstanceMetrics {
-		registry.MustRegister(newInstanceCollector(client, e.logger))
+	// Collect concurrently, so that a slow collector does not eat up the scrape timeout of the others.
+	var wg sync.WaitGroup
+	for _, c := range collectors {
+		wg.Add(1)
+		go func(c prometheus.Collector) {
+			defer wg.Done()
+			prefetch(c)
+		}(c)
 	}
+	wg.Wait()
+
+	labels := identityLabels(opts)
 
-	return registry
+	for i, c := range collectors {
+		registerWithLabels(registry, labels, c, e.logger)
+
+		results[names[i]] = bases[i].result()
+		e.observeResult(names[i], results[names[i]])
+	}
+
+	registry.MustRegister(newResultCollector(e.logger, results))
+
+	return registry, results
 }
 
 func (e *Exporter) getClient(ctx context.Context) (*mongo.Client, error) {
-	connOpts := mongoutils.ConnectionOpts{
-		URI:              e.opts.URI,
-		User:             e.opts.User,
-		Password:         e.opts.Password,
-		DirectConnect:    e.opts.DirectConnect,
-		ConnectTimeoutMS: int64(e.opts.ConnectTimeoutMS),
-	}
+	opts := e.getOpts()
 
-	if e.opts.GlobalConnPool {
+	if opts.GlobalConnPool {
 		// Get global client. Maybe it must be initialized first.
 		// Initialization is retried with every scrape until it succeeds once.
 		e.clientMu.Lock()
-		defer e.clientMu.Unlock()
+		client := e.client
+		e.clientMu.Unlock()
 
 		// If client is already initialized, return it.
-		if e.client != nil {
-			return e.client, nil
+		if client != nil {
+			return client, nil
 		}
 
-		client, err := mongoutils.Connect(context.Background(), &connOpts)
+		// Connect without the lock and within the deadline of the caller, so that the other
+		// scrapes and the readiness checks are not blocked while MongoDB is unavailable.
+		client, err := e.connect(ctx)
 		if err != nil {
 			return nil, err
 		}
-		e.client = client
+
+		e.clientMu.Lock()
+		current := e.client
+		stale := current != nil || e.getOpts() != opts
+		if !stale {
+			e.client = client
+		}
+		e.clientMu.Unlock()
+
+		if stale {
+			// Connected or reloaded by another caller in the meantime.
+			disconnect(client, opts)
+			if current == nil {
+				return e.getClient(ctx)
+			}
+			return current, nil
+		}
 
 		return client, nil
 	}
 
-	// !e.opts.GlobalConnPool: create new client for every scrape.
-	client, err := mongoutils.Connect(ctx, &connOpts)
-	if err != nil {
-		return nil, err
+	// !opts.GlobalConnPool: create new client for every scrape.
+	return e.connect(ctx)
+}
+
+// connect creates a new client with the current options.
+func (e *Exporter) connect(ctx context.Context) (*mongo.Client, error) {
+	opts := e.getOpts()
+	connOpts := mongoutils.ConnectionOpts{
+		URI:              opts.URI,
+		User:             opts.User,
+		Password:         opts.Password,
+		DirectConnect:    opts.DirectConnect,
+		ConnectTimeoutMS: int64(opts.ConnectTimeoutMS),
 	}
 
-	return client, nil
+	if e.target != "" {
+		connOpts.Hosts = []string{e.target}
+	}
+
+	connOpts.TLS = tlsOpts(opts)
+	connOpts.AuthMechanism = opts.AuthMechanism
+
+	return mongoutils.Connect(ctx, &connOpts)
 }
 
 // Handler returns an http.Handler that serves metrics. Can be used instead of
 // run for hooking up custom HTTP servers.
 func (e *Exporter) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.lock.Lock()
+		opts := e.opts
+		topology := e.topology
+		caches := e.caches
+		e.lock.Unlock()
+
+		filters := r.URL.Query()["collect[]"]
+
+		if caches != nil {
+			if topology == nil {
+				topology = opts
+			}
+			e.serveCaches(w, r, caches, enabledCollectors(topology, filters), identityLabels(topology))
+			return
+		}
+
 		seconds, err := strconv.Atoi(r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"))
 		// To support also older ones vmagents.
 		if err != nil {
 			seconds = 10
 		}
-		seconds -= e.opts.TimeoutOffset
+		seconds -= opts.TimeoutOffset
 
 		var client *mongo.Client
 		ctx, cancel := context.WithTimeout(r.Context(), time.Duration(seconds)*time.Second)
 		defer cancel()
 
-		filters := r.URL.Query()["collect[]"]
-
-		requestOpts := Opts{}
-
-		if len(filters) == 0 {
-			requestOpts = *e.opts
-		}
-
-		for _, filter := range filters {
-			switch filter {
-			case "replicasetstatus":
-				requestOpts.EnableReplicasetStatus = true
-			case "oplogstatus":
-				requestOpts.EnableOplogStats = true
-			case "currentopmetrics":
-				requestOpts.EnableCurrentopMetrics = true
-			case "topmetrics":
-				requestOpts.EnableTopMetrics = true
-			case "lvmsnapshotstats":
-				requestOpts.EnableLVMSnapshotStats = true
-			case "rollbackstats":
-				requestOpts.EnableRollbackStats = true
-			}
-		}
-
 		client, err = e.getClient(ctx)
 		if err != nil {
 			e.logger.Errorf("Cannot connect to MongoDB: %v", err)
 		}
 
 		// Close client after usage.
-		if !e.opts.GlobalConnPool {
+		if !opts.GlobalConnPool {
 			defer func() {
 				if client != nil {
 					err := client.Disconnect(ctx)
@@ -255,9 +558,22 @@ func (e *Exporter) Handler() http.Handler {
 			}()
 		}
 
+		// Without a client or a detected server, every enabled collector fails.
+		specs := enabledCollectors(opts, filters)
+		var unavailable error
+		if client == nil {
+			unavailable = &unavailableError{class: "connection", err: err}
+		} else if detected, err := e.detect(ctx, client); err != nil {
+			e.logger.Errorf("Cannot detect MongoDB server: %v", err)
+			unavailable = &unavailableError{class: "detection", err: err}
+		} else {
+			specs = enabledCollectors(detected, filters)
+			opts = detected
+		}
+
 		var gatherers prometheus.Gatherers
 
-		registry := e.makeRegistry(client)
+		registry, _ := e.makeRegistry(ctx, client, opts, specs, unavailable)
 		gatherers = append(gatherers, registry)
 
 		// Delegate http serving to Prometheus client library, which will call collector.Collect.
@@ -0,0 +1,74 @@
+package exporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResolveCollectorsDeprecatedOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Opts
+		want map[string]bool
+	}{
+		{
+			name: "collectors",
+			opts: Opts{Collectors: []string{"topmetrics", "oplogstats"}},
+			want: map[string]bool{"topmetrics": true, "oplogstats": true},
+		},
+		{
+			name: "deprecated options",
+			opts: Opts{EnableShardingStats: true, EnableInstanceMetrics: true},
+			want: map[string]bool{"shardstats": true, "instance": true},
+		},
+		{
+			name: "deprecated options with collectors",
+			opts: Opts{EnableTopMetrics: true, Collectors: []string{"topmetrics", "replevents"}},
+			want: map[string]bool{"topmetrics": true, "replevents": true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := tt.opts
+			if err := resolveCollectors(&opts); err != nil {
+				t.Fatalf("resolveCollectors() error = %v", err)
+			}
+			if !reflect.DeepEqual(opts.enabled, tt.want) {
+				t.Errorf("enabled = %v, want %v", opts.enabled, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileNamespaceFilterSystemCollections(t *testing.T) {
+	tests := []struct {
+		name               string
+		excludeCollections []string
+		ns                 string
+		wantAll            bool
+		wantSystem         bool
+	}{
+		{name: "user collection", ns: "app.orders", wantAll: true, wantSystem: true},
+		{name: "system collection by default", ns: "app.system.profile", wantAll: true, wantSystem: false},
+		{name: "system database by default", ns: "local.oplog.rs", wantAll: false, wantSystem: false},
+		{name: "no collection excluded", excludeCollections: []string{}, ns: "app.system.profile", wantAll: true, wantSystem: true},
+		{name: "collections excluded", excludeCollections: []string{`^system\.js$`}, ns: "app.system.js", wantAll: false, wantSystem: false},
+		{name: "only the given collections excluded", excludeCollections: []string{`^system\.js$`}, ns: "app.system.profile", wantAll: true, wantSystem: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := Opts{ExcludeCollections: tt.excludeCollections}
+			if err := compileNamespaceFilter(&opts); err != nil {
+				t.Fatalf("compileNamespaceFilter() error = %v", err)
+			}
+			if got := opts.nsFilter.Match(tt.ns); got != tt.wantAll {
+				t.Errorf("nsFilter.Match(%q) = %v, want %v", tt.ns, got, tt.wantAll)
+			}
+			if got := opts.systemNsFilter.Match(tt.ns); got != tt.wantSystem {
+				t.Errorf("systemNsFilter.Match(%q) = %v, want %v", tt.ns, got, tt.wantSystem)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"mobserver/internal/mongoutils"
+	"net/http"
+	"time"
+)
+
+const readyTimeout = 3 * time.Second
+
+// HealthyHandler returns an http.Handler that responds OK while the process is alive.
+func (e *Exporter) HealthyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Healthy")
+	})
+}
+
+// ReadyHandler returns an http.Handler that responds OK only if MongoDB answers hello within
+// a short deadline, so that the exporter stops receiving traffic while its client is stuck.
+func (e *Exporter) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		if err := e.ping(ctx); err != nil {
+			e.logger.Errorf("Not ready: %v", err)
+			http.Error(w, fmt.Sprintf("not ready: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+
+		fmt.Fprintln(w, "Ready")
+	})
+}
+
+// ping checks that a client can be obtained and that MongoDB answers hello.
+func (e *Exporter) ping(ctx context.Context) error {
+	client, err := e.getClient(ctx)
+	if err != nil {
+		return fmt.Errorf("cannot connect to MongoDB: %w", err)
+	}
+
+	if !e.getOpts().GlobalConnPool {
+		defer func() {
+			if err := client.Disconnect(ctx); err != nil {
+				e.logger.Errorf("Cannot disconnect client: %v", err)
+			}
+		}()
+	}
+
+	if _, err := mongoutils.GetHello(ctx, client); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+package exporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestReadyHandlerUnreachableServer(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Nothing listens on port 1, and the connect timeout is longer than the deadline of the readiness check.
+	exp, err := NewWithContext(ctx, &Opts{
+		URI:              "mongodb://127.0.0.1:1",
+		DirectConnect:    true,
+		ConnectTimeoutMS: int((30 * time.Second).Milliseconds()),
+		GlobalConnPool:   true,
+		Logger:           logger,
+	})
+	if err != nil {
+		t.Fatalf("NewWithContext() error = %v", err)
+	}
+
+	start := time.Now()
+	rec := httptest.NewRecorder()
+	exp.ReadyHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/-/ready", nil))
+	elapsed := time.Since(start)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if elapsed > readyTimeout+time.Second {
+		t.Errorf("answered after %s, want within %s", elapsed, readyTimeout)
+	}
+}
@@ -0,0 +1,293 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"mobserver/internal/metric"
+	"mobserver/internal/mongoutils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	sysctlDir   = "/proc/sys/vm"
+	thpDir      = "/sys/kernel/mm/transparent_hugepage"
+	blockDevDir = "/sys/dev/block"
+	numaNodeDir = "/sys/devices/system/node"
+)
+
+// The readahead of the dbPath device recommended for WiredTiger is 8 to 32 sectors of 512 bytes.
+const (
+	minReadaheadKB = 4
+	maxReadaheadKB = 16
+)
+
+// minMaxMapCount is twice the maximum number of connections of mongod, each of which maps memory.
+const minMaxMapCount = 128000
+
+// filesystemTypes is the names of the filesystems by the magic number of statfs.
+var filesystemTypes = map[int64]string{
+	0x58465342: "xfs",
+	0xEF53:     "ext4",
+	0x9123683E: "btrfs",
+	0x2FC12FC1: "zfs",
+	0x01021994: "tmpfs",
+	0x794C7630: "overlay",
+}
+
+// hostSettingsCollector checks the kernel settings of the local host against the production notes of MongoDB.
+type hostSettingsCollector struct {
+	ctx  context.Context
+	base *baseCollector
+
+	isMongos bool
+}
+
+// hostSettingCheck reads a setting and checks it, with an error of os.IsNotExist if it does not apply to the host.
+type hostSettingCheck struct {
+	name  string
+	check func() (*metric.HostSetting, error)
+}
+
+func newHostSettingsCollector(base *baseCollector, isMongos bool) prometheus.Collector {
+	return &hostSettingsCollector{
+		ctx:  base.ctx,
+		base: base,
+
+		isMongos: isMongos,
+	}
+}
+
+func (c *hostSettingsCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *hostSettingsCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *hostSettingsCollector) collect(ch chan<- prometheus.Metric) error {
+	serverStatus, err := mongoutils.GetServerStatus(c.ctx, c.base.client)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get server status: %v", err)
+		return err
+	}
+
+	// The hugepages are recommended from MongoDB 8.0 on, with TCMalloc of per-CPU caches.
+	thpEnabled, thpDefrag := "never", "never"
+	if majorVersion(serverStatus.Version) >= 8 {
+		thpEnabled, thpDefrag = "always", "defer+madvise"
+	}
+
+	checks := []hostSettingCheck{
+		{"transparent_hugepage_enabled", func() (*metric.HostSetting, error) {
+			return checkTHP(filepath.Join(thpDir, "enabled"), thpEnabled)
+		}},
+		{"transparent_hugepage_defrag", func() (*metric.HostSetting, error) {
+			return checkTHP(filepath.Join(thpDir, "defrag"), thpDefrag)
+		}},
+		{"swappiness", func() (*metric.HostSetting, error) {
+			return checkSysctl("swappiness", func(v float64) bool { return v <= 1 })
+		}},
+		{"zone_reclaim_mode", func() (*metric.HostSetting, error) {
+			return checkSysctl("zone_reclaim_mode", func(v float64) bool { return v == 0 })
+		}},
+		{"max_map_count", func() (*metric.HostSetting, error) {
+			return checkSysctl("max_map_count", func(v float64) bool { return v >= minMaxMapCount })
+		}},
+		{"numa_interleave", func() (*metric.HostSetting, error) {
+			return checkNUMAInterleave(serverStatus.Pid)
+		}},
+	}
+
+	// mongos has no dbPath.
+	if !c.isMongos {
+		cmdLineOpts, err := mongoutils.GetCmdLineOpts(c.ctx, c.base.client)
+		if err != nil {
+			c.base.logger.Errorf("Failed to get command line options: %v", err)
+			return err
+		}
+
+		dbPath := cmdLineOpts.Parsed.Storage.DBPath
+		if dbPath == "" {
+			dbPath = defaultDBPath
+		}
+
+		checks = append(checks,
+			hostSettingCheck{"readahead_kb", func() (*metric.HostSetting, error) { return checkReadahead(dbPath) }},
+			hostSettingCheck{"filesystem", func() (*metric.HostSetting, error) { return checkFilesystem(dbPath) }},
+		)
+	}
+
+	var firstErr error
+
+	for _, check := range checks {
+		setting, err := check.check()
+		if os.IsNotExist(err) {
+			// e.g. no hugepages in the kernel, or no device under a filesystem in memory
+			c.base.logger.Debugf("Skipping %s: %v", check.name, err)
+			continue
+		} else if err != nil {
+			c.base.logger.Errorf("Failed to check %s: %v", check.name, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		setting.Name = check.name
+		for _, mt := range setting.ToPromMetrics() {
+			ch <- mt
+		}
+	}
+
+	return firstErr
+}
+
+// majorVersion returns the major version of MongoDB, 0 if the version cannot be parsed.
+func majorVersion(version string) int {
+	major, err := strconv.Atoi(strings.SplitN(version, ".", 2)[0])
+	if err != nil {
+		return 0
+	}
+
+	return major
+}
+
+// checkTHP reads the mode of transparent hugepages, which is in brackets, e.g. "always [madvise] never".
+func checkTHP(path, expected string) (*metric.HostSetting, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mode := ""
+	for _, field := range strings.Fields(string(content)) {
+		if strings.HasPrefix(field, "[") && strings.HasSuffix(field, "]") {
+			mode = strings.Trim(field, "[]")
+		}
+	}
+	if mode == "" {
+		return nil, fmt.Errorf("no mode in %s", path)
+	}
+
+	return &metric.HostSetting{Value: mode, Number: math.NaN(), Compliant: mode == expected}, nil
+}
+
+func checkSysctl(name string, compliant func(float64) bool) (*metric.HostSetting, error) {
+	v, value, err := readNumber(filepath.Join(sysctlDir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return &metric.HostSetting{Value: value, Number: v, Compliant: compliant(v)}, nil
+}
+
+// checkNUMAInterleave reads the memory policy of the MongoDB process, which must interleave
+// the memory of the NUMA nodes if there are several of them. The number is the number of the nodes.
+func checkNUMAInterleave(pid int64) (*metric.HostSetting, error) {
+	nodes, err := filepath.Glob(filepath.Join(numaNodeDir, "node[0-9]*"))
+	if err != nil {
+		return nil, err
+	}
+
+	setting := &metric.HostSetting{Value: "default", Number: float64(len(nodes)), Compliant: len(nodes) <= 1}
+	if len(nodes) <= 1 {
+		return setting, nil
+	}
+
+	dir, err := mongoProcessDir(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	// The policy is the second field of every mapping, e.g. "7f6c1c000000 interleave:0-1 anon=1".
+	content, err := os.ReadFile(filepath.Join(dir, "numa_maps"))
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("no memory policy of process %d", pid)
+	}
+
+	setting.Value = fields[1]
+	setting.Compliant = strings.HasPrefix(fields[1], "interleave")
+
+	return setting, nil
+}
+
+// checkReadahead reads the readahead of the block device of dbPath. A partition has no queue,
+// which is of the disk of the partition.
+func checkReadahead(dbPath string) (*metric.HostSetting, error) {
+	var stat syscall.Stat_t
+	if err := syscall.Stat(dbPath, &stat); err != nil {
+		return nil, &os.PathError{Op: "stat", Path: dbPath, Err: err}
+	}
+
+	major, minor := deviceNumbers(uint64(stat.Dev))
+	if major == 0 {
+		return nil, &os.PathError{Op: "readahead", Path: dbPath, Err: os.ErrNotExist}
+	}
+
+	device, err := filepath.EvalSymlinks(filepath.Join(blockDevDir, fmt.Sprintf("%d:%d", major, minor)))
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(device, "queue", "read_ahead_kb")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		path = filepath.Join(filepath.Dir(device), "queue", "read_ahead_kb")
+	}
+
+	v, value, err := readNumber(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &metric.HostSetting{Value: value, Number: v, Compliant: v >= minReadaheadKB && v <= maxReadaheadKB}, nil
+}
+
+// deviceNumbers decodes the major and minor numbers of a device number of Linux.
+func deviceNumbers(dev uint64) (uint64, uint64) {
+	major := (dev>>8)&0xfff | (dev>>32)&^0xfff
+	minor := dev&0xff | (dev>>12)&^0xff
+
+	return major, minor
+}
+
+func checkFilesystem(dbPath string) (*metric.HostSetting, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dbPath, &stat); err != nil {
+		return nil, &os.PathError{Op: "statfs", Path: dbPath, Err: err}
+	}
+
+	name, ok := filesystemTypes[int64(stat.Type)]
+	if !ok {
+		name = fmt.Sprintf("0x%x", stat.Type)
+	}
+
+	return &metric.HostSetting{Value: name, Number: math.NaN(), Compliant: name == "xfs"}, nil
+}
+
+// readNumber reads a file of a single number, and returns it with the raw value.
+func readNumber(path string) (float64, string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, "", err
+	}
+
+	value := strings.TrimSpace(string(content))
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid number %q in %s", value, path)
+	}
+
+	return v, value, nil
+}
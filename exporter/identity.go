@@ -0,0 +1,121 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	pmodel "github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Names of the identity labels.
+const (
+	// IdentityReplset is setName of hello.
+	IdentityReplset = "replset"
+	// IdentityHost is me of hello.
+	IdentityHost = "host"
+	// IdentityShard is the name of the shard, set only on the members of a shard.
+	IdentityShard = "shard"
+	// IdentityCluster is the id of the sharded cluster.
+	IdentityCluster = "cluster"
+)
+
+var (
+	// ErrUnknownIdentityLabel is returned when an identity label is not one of the names above.
+	ErrUnknownIdentityLabel = errors.New("unknown identity label, must be one of replset, host, shard, cluster")
+	// ErrInvalidLabelName is returned when a constant label is not a valid Prometheus label name.
+	ErrInvalidLabelName = errors.New("invalid label name")
+)
+
+// detectIdentity discovers the values of the identity labels. The ones which cannot be discovered are left empty.
+func detectIdentity(ctx context.Context, client *mongo.Client, logger *logrus.Logger, hello *model.HelloDoc,
+	cmdLineOpts *model.CmdLineOptsDoc) map[string]string {
+	identity := map[string]string{
+		IdentityReplset: hello.SetName,
+		IdentityHost:    hello.Me,
+	}
+
+	var clusterRole string
+	if cmdLineOpts != nil {
+		clusterRole = cmdLineOpts.Parsed.Sharding.ClusterRole
+	}
+
+	switch {
+	case clusterRole == "shardsvr":
+		state, err := mongoutils.GetShardingState(ctx, client)
+		if err != nil {
+			logger.Debugf("Cannot get shard identity: %v", err)
+			break
+		}
+
+		identity[IdentityShard] = state.ShardName
+		if !state.ClusterID.IsZero() {
+			identity[IdentityCluster] = state.ClusterID.Hex()
+		}
+	case clusterRole == "configsvr" || hello.Msg == "isdbgrid":
+		version, err := mongoutils.GetConfigVersion(ctx, client)
+		if err != nil {
+			logger.Debugf("Cannot get cluster identity: %v", err)
+			break
+		}
+
+		identity[IdentityCluster] = version.ClusterID.Hex()
+	}
+
+	return identity
+}
+
+func validateLabelOpts(opts *Opts) error {
+	for _, name := range opts.IdentityLabels {
+		switch name {
+		case IdentityReplset, IdentityHost, IdentityShard, IdentityCluster:
+		default:
+			return &ValidationError{Option: "IdentityLabels", Value: name, Err: ErrUnknownIdentityLabel}
+		}
+	}
+
+	for name := range opts.ConstLabels {
+		if !pmodel.LabelName(name).IsValid() {
+			return &ValidationError{Option: "ConstLabels", Value: name, Err: ErrInvalidLabelName}
+		}
+	}
+
+	return nil
+}
+
+// identityLabels returns the labels to add to the metrics of the collectors.
+// The identity labels whose values are not discovered are omitted.
+func identityLabels(opts *Opts) prometheus.Labels {
+	labels := make(prometheus.Labels)
+
+	for _, name := range opts.IdentityLabels {
+		if value := opts.identity[name]; value != "" {
+			labels[name] = value
+		}
+	}
+
+	for name, value := range opts.ConstLabels {
+		labels[name] = value
+	}
+
+	return labels
+}
+
+// registerWithLabels registers the collector with the labels on all of its metrics. If the labels
+// conflict with the labels of its metrics, the collector is registered without them.
+func registerWithLabels(registry *prometheus.Registry, labels prometheus.Labels, c prometheus.Collector, logger *logrus.Logger) {
+	if len(labels) > 0 {
+		err := prometheus.WrapRegistererWith(labels, registry).Register(c)
+		if err == nil {
+			return
+		}
+
+		logger.Warnf("Cannot add the identity labels to the metrics: %v", err)
+	}
+
+	registry.MustRegister(c)
+}
@@ -3,13 +3,12 @@ package exporter
 import (
 	"context"
 	"mobserver/internal/metric"
+	"mobserver/internal/mongoutils"
 	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type instanceCollector struct {
@@ -17,10 +16,10 @@ type instanceCollector struct {
 	base *baseCollector
 }
 
-func newInstanceCollector(client *mongo.Client, logger *logrus.Logger) prometheus.Collector {
+func newInstanceCollector(base *baseCollector) prometheus.Collector {
 	return &instanceCollector{
-		ctx:  context.Background(),
-		base: newBaseCollector(client, logger),
+		ctx:  base.ctx,
+		base: base,
 	}
 }
 
@@ -32,21 +31,22 @@ func (c *instanceCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *instanceCollector) collect(ch chan<- prometheus.Metric) {
+func (c *instanceCollector) collect(ch chan<- prometheus.Metric) error {
 	doc := struct {
 		Version string `bson:"version"`
 	}{}
 	cmd := bson.D{{Key: "buildinfo", Value: 1}}
-	err := c.base.client.Database("admin").RunCommand(context.TODO(), cmd).Decode(&doc)
+	err := c.base.client.Database("admin").RunCommand(c.ctx, mongoutils.WithMaxTime(c.ctx, cmd)).Decode(&doc)
 	if err != nil {
-		return
+		c.base.logger.Errorf("Failed to get buildinfo command: %v", err)
+		return err
 	}
 
 	v := strings.Split(doc.Version, ".")
 	majorVersion, err := strconv.ParseFloat(v[0]+"."+v[1], 64)
 	if err != nil {
-		logrus.Errorf("Failed to parse major version: %v", err)
-		return
+		c.base.logger.Errorf("Failed to parse major version: %v", err)
+		return err
 	}
 
 	ist := &metric.Instance{
@@ -56,4 +56,6 @@ func (c *instanceCollector) collect(ch chan<- prometheus.Metric) {
 	for _, m := range ist.ToPromMetrics() {
 		ch <- m
 	}
+
+	return nil
 }
@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"mobserver/internal/metric"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// newBase creates the base of a collection of the collector, with the namespace limit of the options,
+// and the limiter of the collector and the replication event tracker, which keep their state across the collections.
+func (e *Exporter) newBase(ctx context.Context, name string, client *mongo.Client, opts *Opts) *baseCollector {
+	base := newBaseCollector(ctx, name, client, e.logger)
+	base.nsLimit = maxNamespaces(opts, name)
+	base.nsLimiter = e.namespaceLimiter(name)
+	base.replEvents = e.replEventTracker()
+
+	return base
+}
+
+func (e *Exporter) namespaceLimiter(name string) *metric.NamespaceLimiter {
+	e.limitersMu.Lock()
+	defer e.limitersMu.Unlock()
+
+	if e.limiters == nil {
+		e.limiters = make(map[string]*metric.NamespaceLimiter)
+	}
+
+	limiter, ok := e.limiters[name]
+	if !ok {
+		limiter = metric.NewNamespaceLimiter()
+		e.limiters[name] = limiter
+	}
+
+	return limiter
+}
+
+func validateLimitOpts(opts *Opts) error {
+	if opts.MaxNamespaces < 0 {
+		return &ValidationError{Option: "MaxNamespaces", Value: strconv.Itoa(opts.MaxNamespaces), Err: ErrNegativeLimit}
+	}
+
+	for name, limit := range opts.CollectorMaxNamespaces {
+		if lookupCollector(name) == nil {
+			return &ValidationError{
+				Option: "CollectorMaxNamespaces",
+				Value:  name,
+				Err:    fmt.Errorf("%w, must be one of %s", ErrUnknownCollector, strings.Join(CollectorNames(), ", ")),
+			}
+		}
+		if limit < 0 {
+			return &ValidationError{Option: "CollectorMaxNamespaces", Value: name, Err: ErrNegativeLimit}
+		}
+	}
+
+	return nil
+}
+
+func maxNamespaces(opts *Opts, name string) int {
+	if limit, ok := opts.CollectorMaxNamespaces[name]; ok {
+		return limit
+	}
+
+	return opts.MaxNamespaces
+}
+
+// limitNamespaces returns the namespaces to collect separately, the nsLimit ones with the highest
+// activity. The others are to be aggregated into metric.OtherNamespace.
+func (d *baseCollector) limitNamespaces(activity map[string]float64, cumulative bool) map[string]bool {
+	return d.nsLimiter.Select(d.nsLimit, activity, cumulative)
+}
+
+// namespacesDropped returns the metrics of the number of namespaces aggregated into metric.OtherNamespace.
+func (d *baseCollector) namespacesDropped(dropped int) []prometheus.Metric {
+	return metric.NamespacesDroppedToPromMetrics(d.name, dropped)
+}
@@ -6,12 +6,11 @@ import (
 	"fmt"
 	"mobserver/internal/metric"
 	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type oplogCollector struct {
@@ -19,10 +18,10 @@ type oplogCollector struct {
 	base *baseCollector
 }
 
-func newOplogCollector(client *mongo.Client, logger *logrus.Logger) prometheus.Collector {
+func newOplogCollector(base *baseCollector) prometheus.Collector {
 	return &oplogCollector{
-		ctx:  context.Background(),
-		base: newBaseCollector(client, logger),
+		ctx:  base.ctx,
+		base: base,
 	}
 }
 
@@ -34,27 +33,27 @@ func (c *oplogCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *oplogCollector) collect(ch chan<- prometheus.Metric) {
+func (c *oplogCollector) collect(ch chan<- prometheus.Metric) error {
 	db := c.base.client.Database("local")
 	coll := db.Collection("oplog.rs")
 
 	var oplogSize model.CollSize
-	if err := db.RunCommand(c.ctx, bson.D{{Key: "collStats", Value: "oplog.rs"}}).Decode(&oplogSize); err != nil {
+	if err := db.RunCommand(c.ctx, mongoutils.WithMaxTime(c.ctx, bson.D{{Key: "collStats", Value: "oplog.rs"}})).Decode(&oplogSize); err != nil {
 		c.base.logger.Errorf("Failed to get oplog size: %v", err)
-		return
+		return err
 	}
 
 	// get first and last items in the oplog
 	firstTs, err := getOpTimestamp(c.ctx, coll, bson.D{{Key: "$natural", Value: 1}})
 	if err != nil {
 		c.base.logger.Errorf("Failed to get first oplog timestamp: %v", err)
-		return
+		return err
 	}
 
 	lastTs, err := getOpTimestamp(c.ctx, coll, bson.D{{Key: "$natural", Value: -1}})
 	if err != nil {
 		c.base.logger.Errorf("Failed to get last oplog timestamp: %v", err)
-		return
+		return err
 	}
 
 	diff := lastTs - firstTs
@@ -70,12 +69,14 @@ func (c *oplogCollector) collect(ch chan<- prometheus.Metric) {
 	for _, mt := range mt.ToPromMetrics() {
 		ch <- mt
 	}
+
+	return nil
 }
 
 func getOpTimestamp(ctx context.Context, collection *mongo.Collection, sort bson.D) (int64, error) {
 	var limit int64 = 1
 
-	opts := options.Find()
+	opts := mongoutils.FindOptions(ctx)
 	opts.SetSort(sort)
 	opts.SetLimit(limit)
 	cursor, err := collection.Find(ctx, bson.D{}, opts)
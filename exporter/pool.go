@@ -0,0 +1,171 @@
+package exporter
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	defaultPoolCheckInterval = 30 * time.Second
+	defaultPoolMaxFailures   = 3
+	maxPoolRebuildBackoff    = 5 * time.Minute
+)
+
+// connPoolMetrics counts the health checks and the rebuilds of the global client.
+type connPoolMetrics struct {
+	checks              *prometheus.CounterVec
+	reconnects          *prometheus.CounterVec
+	consecutiveFailures prometheus.Gauge
+}
+
+func newConnPoolMetrics() *connPoolMetrics {
+	return &connPoolMetrics{
+		checks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mobserver_conn_pool_health_checks_total",
+			Help: "Number of health checks of the global connection pool by the result (success, failure)",
+		}, []string{"result"}),
+		reconnects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mobserver_conn_pool_reconnects_total",
+			Help: "Number of rebuilds of the global connection pool by the result (success, failure)",
+		}, []string{"result"}),
+		consecutiveFailures: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mobserver_conn_pool_consecutive_failures",
+			Help: "Number of consecutive failed health checks of the global connection pool",
+		}),
+	}
+}
+
+func (m *connPoolMetrics) Describe(ch chan<- *prometheus.Desc) {
+	m.checks.Describe(ch)
+	m.reconnects.Describe(ch)
+	m.consecutiveFailures.Describe(ch)
+}
+
+func (m *connPoolMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.checks.Collect(ch)
+	m.reconnects.Collect(ch)
+	m.consecutiveFailures.Collect(ch)
+}
+
+// poolHealth is the state of the health checks of the global client.
+type poolHealth struct {
+	failures    int
+	backoff     time.Duration
+	nextRebuild time.Time
+}
+
+// checkPoolHealth pings the global client periodically until ctx is done. Once the client fails
+// PoolMaxFailures checks in a row, it is replaced by a new one, retrying with exponential backoff.
+// Nothing is checked while the global connection pool is disabled or the client is not created yet.
+func (e *Exporter) checkPoolHealth(ctx context.Context) {
+	health := &poolHealth{}
+
+	for {
+		interval := poolCheckInterval(e.getOpts())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		e.checkPool(ctx, health, interval)
+	}
+}
+
+func poolCheckInterval(opts *Opts) time.Duration {
+	if opts.PoolCheckInterval > 0 {
+		return opts.PoolCheckInterval
+	}
+
+	return defaultPoolCheckInterval
+}
+
+func (e *Exporter) checkPool(ctx context.Context, health *poolHealth, timeout time.Duration) {
+	opts := e.getOpts()
+	if !opts.GlobalConnPool {
+		return
+	}
+
+	e.clientMu.Lock()
+	client := e.client
+	e.clientMu.Unlock()
+
+	if client == nil {
+		// getClient creates it on the next scrape.
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := client.Ping(ctx, nil)
+	if err == nil {
+		e.poolMetrics.checks.WithLabelValues("success").Inc()
+		e.poolMetrics.consecutiveFailures.Set(0)
+		*health = poolHealth{}
+		return
+	}
+
+	e.logger.Warnf("Health check of the global connection pool failed: %v", err)
+	e.poolMetrics.checks.WithLabelValues("failure").Inc()
+	health.failures++
+	e.poolMetrics.consecutiveFailures.Set(float64(health.failures))
+
+	maxFailures := opts.PoolMaxFailures
+	if maxFailures <= 0 {
+		maxFailures = defaultPoolMaxFailures
+	}
+
+	if health.failures < maxFailures || time.Now().Before(health.nextRebuild) {
+		return
+	}
+
+	if err := e.rebuildClient(ctx, client); err != nil {
+		e.logger.Errorf("Cannot rebuild the global connection pool: %v", err)
+		e.poolMetrics.reconnects.WithLabelValues("failure").Inc()
+
+		health.backoff *= 2
+		if health.backoff == 0 {
+			health.backoff = timeout
+		}
+		if health.backoff > maxPoolRebuildBackoff {
+			health.backoff = maxPoolRebuildBackoff
+		}
+		health.nextRebuild = time.Now().Add(health.backoff)
+		return
+	}
+
+	e.logger.Warnf("Rebuilt the global connection pool after %d failed health checks", health.failures)
+	e.poolMetrics.reconnects.WithLabelValues("success").Inc()
+	e.poolMetrics.consecutiveFailures.Set(0)
+	*health = poolHealth{}
+}
+
+// rebuildClient replaces the global client with a new one, unless it was already replaced.
+func (e *Exporter) rebuildClient(ctx context.Context, prev *mongo.Client) error {
+	client, err := e.connect(ctx)
+	if err != nil {
+		return err
+	}
+
+	e.clientMu.Lock()
+	replaced := e.client != prev
+	if !replaced {
+		e.client = client
+	}
+	e.clientMu.Unlock()
+
+	if replaced {
+		// Reloaded in the meantime.
+		disconnect(client, e.getOpts())
+		return nil
+	}
+
+	disconnect(prev, e.getOpts())
+
+	return nil
+}
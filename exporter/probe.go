@@ -0,0 +1,246 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultProbeTargetTTL = 5 * time.Minute
+
+type probeTarget struct {
+	lock     sync.Mutex
+	exporter *Exporter
+	// lastUsed is the time of the last probe of the target, after which it is evicted once ProbeTargetTTL passes.
+	lastUsed time.Time
+	// stop stops the health checks of the connection pool of the target.
+	stop context.CancelFunc
+	// closed is set when the target is dropped, so that the probes waiting for it look it up again.
+	closed bool
+}
+
+// close stops the health checks of the target and disconnects its client. t.lock must be held.
+func (t *probeTarget) close() {
+	t.closed = true
+	if t.stop != nil {
+		t.stop()
+	}
+	if t.exporter == nil {
+		return
+	}
+
+	t.exporter.clientMu.Lock()
+	client := t.exporter.client
+	t.exporter.client = nil
+	t.exporter.clientMu.Unlock()
+
+	if client != nil {
+		disconnect(client, t.exporter.opts)
+	}
+}
+
+// ProbeHandler returns an http.Handler that serves metrics of the MongoDB given by
+// the target parameter (e.g. /scrape?target=host:port), like blackbox_exporter does.
+// Every target uses the credentials and options of this exporter, but keeps its own
+// client and topology. Only the targets of ProbeAllowedTargets are probed, so that
+// the credentials are not sent to any host a caller names.
+func (e *Exporter) ProbeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+
+		if !probeAllowed(e.getOpts(), target) {
+			e.logger.Warnf("Rejecting probe of target %s, which is not an allowed target", target)
+			http.Error(w, fmt.Sprintf("target %s is not allowed", target), http.StatusBadRequest)
+			return
+		}
+
+		exp, err := e.getTargetExporter(r.Context(), target)
+		if err != nil {
+			e.logger.Errorf("Cannot probe target %s: %v", target, err)
+			http.Error(w, fmt.Sprintf("cannot probe target %s: %v", target, err), http.StatusServiceUnavailable)
+			return
+		}
+
+		exp.Handler().ServeHTTP(w, r)
+	})
+}
+
+// probeAllowed returns whether the target is one of ProbeAllowedTargets. The hosts are case-insensitive.
+func probeAllowed(opts *Opts, target string) bool {
+	for _, allowed := range opts.ProbeAllowedTargets {
+		if strings.EqualFold(allowed, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func probeTargetTTL(opts *Opts) time.Duration {
+	if opts.ProbeTargetTTL > 0 {
+		return opts.ProbeTargetTTL
+	}
+
+	return defaultProbeTargetTTL
+}
+
+// getTargetExporter returns the cached exporter for the target, creating it on the first probe.
+func (e *Exporter) getTargetExporter(ctx context.Context, target string) (*Exporter, error) {
+	for {
+		e.targetsMu.Lock()
+		t, ok := e.targets[target]
+		if !ok {
+			t = &probeTarget{}
+			e.targets[target] = t
+		}
+		e.targetsMu.Unlock()
+
+		// Lock per target, so that a slow target does not block probes of the others.
+		t.lock.Lock()
+		if t.closed {
+			// Dropped while waiting for the lock.
+			t.lock.Unlock()
+			continue
+		}
+
+		exp, err := e.openTarget(ctx, target, t)
+		t.lock.Unlock()
+
+		return exp, err
+	}
+}
+
+// openTarget returns the exporter of the target, connecting to it unless it is connected. t.lock must be held.
+func (e *Exporter) openTarget(ctx context.Context, target string, t *probeTarget) (*Exporter, error) {
+	t.lastUsed = time.Now()
+
+	if t.exporter != nil {
+		return t.exporter, nil
+	}
+
+	exp, err := e.newTargetExporter(ctx, target)
+	if err != nil {
+		// A failed target is not kept, so that it is connected again on the next probe.
+		t.closed = true
+		e.dropTarget(target, t)
+		return nil, err
+	}
+
+	checkCtx, stop := context.WithCancel(e.ctx)
+	go exp.checkPoolHealth(checkCtx)
+
+	t.exporter = exp
+	t.stop = stop
+
+	return exp, nil
+}
+
+// dropTarget removes the target from the cache, unless it was replaced in the meantime.
+func (e *Exporter) dropTarget(target string, t *probeTarget) {
+	e.targetsMu.Lock()
+	defer e.targetsMu.Unlock()
+
+	if e.targets[target] == t {
+		delete(e.targets, target)
+	}
+}
+
+// evictTargets drops the probe targets which are not probed for ProbeTargetTTL until ctx is done,
+// and disconnects their clients.
+func (e *Exporter) evictTargets(ctx context.Context) {
+	for {
+		ttl := probeTargetTTL(e.getOpts())
+
+		select {
+		case <-ctx.Done():
+			e.resetTargets()
+			return
+		case <-time.After(ttl / 2):
+		}
+
+		e.evictIdleTargets(time.Now().Add(-ttl))
+	}
+}
+
+// evictIdleTargets drops the targets last probed before the time.
+func (e *Exporter) evictIdleTargets(before time.Time) {
+	e.targetsMu.Lock()
+	targets := make(map[string]*probeTarget, len(e.targets))
+	for name, t := range e.targets {
+		targets[name] = t
+	}
+	e.targetsMu.Unlock()
+
+	for name, t := range targets {
+		t.lock.Lock()
+		idle := t.lastUsed.Before(before)
+		if idle {
+			t.close()
+		}
+		t.lock.Unlock()
+
+		if idle {
+			e.logger.Debugf("Dropping probe target %s, which was last probed at %s", name, t.lastUsed.Format(time.RFC3339))
+			e.dropTarget(name, t)
+		}
+	}
+}
+
+func (e *Exporter) newTargetExporter(ctx context.Context, target string) (*Exporter, error) {
+	e.lock.Lock()
+	opts := e.probeOpts
+	e.lock.Unlock()
+
+	if err := resolveCollectors(&opts); err != nil {
+		return nil, err
+	}
+
+	if err := compileNamespaceFilter(&opts); err != nil {
+		return nil, err
+	}
+
+	// The client of a target is kept across the probes.
+	opts.GlobalConnPool = true
+
+	// Some collectors inspect the local host, not the target.
+	for _, spec := range registeredCollectors() {
+		if spec.onlyOnLocalhost {
+			delete(opts.enabled, spec.name)
+		}
+	}
+
+	exp := &Exporter{
+		ctx:             e.ctx,
+		logger:          e.logger,
+		opts:            &opts,
+		lock:            &sync.Mutex{},
+		collectorErrors: newCollectorErrors(),
+		poolMetrics:     newConnPoolMetrics(),
+		target:          target,
+	}
+
+	client, err := exp.getClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to MongoDB: %w", err)
+	}
+
+	topology, reasons, err := detectTopology(ctx, client, exp.opts)
+	if err != nil {
+		if err := client.Disconnect(ctx); err != nil {
+			e.logger.Errorf("Cannot disconnect client: %v", err)
+		}
+		return nil, err
+	}
+	logTopologyChanges(e.logger, nil, topology, reasons)
+	exp.topology = topology
+	exp.detectedAt = time.Now()
+
+	return exp, nil
+}
@@ -0,0 +1,253 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"mobserver/internal/metric"
+	"mobserver/internal/mongoutils"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	procDir   = "/proc"
+	cgroupDir = "/sys/fs/cgroup"
+)
+
+// cgroupV1Unlimited is the memory limit from which a cgroup v1 is unlimited, which is the largest
+// multiple of the page size instead of "max" of cgroup v2.
+const cgroupV1Unlimited = 1 << 62
+
+// processCollector reads the status of the MongoDB process from /proc, with its PID from serverStatus.
+type processCollector struct {
+	ctx  context.Context
+	base *baseCollector
+}
+
+func newProcessCollector(base *baseCollector) prometheus.Collector {
+	return &processCollector{
+		ctx:  base.ctx,
+		base: base,
+	}
+}
+
+func (c *processCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *processCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *processCollector) collect(ch chan<- prometheus.Metric) error {
+	serverStatus, err := mongoutils.GetServerStatus(c.ctx, c.base.client)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get server status: %v", err)
+		return err
+	}
+
+	status, err := c.getProcessStatus(serverStatus.Pid)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get process status: %v", err)
+		return err
+	}
+
+	for _, mt := range status.ToPromMetrics() {
+		ch <- mt
+	}
+
+	return nil
+}
+
+func (c *processCollector) getProcessStatus(pid int64) (*metric.ProcessStatus, error) {
+	dir, err := mongoProcessDir(pid)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &metric.ProcessStatus{
+		OpenFDs:           math.NaN(),
+		MaxFDs:            math.NaN(),
+		CgroupMemoryLimit: math.NaN(),
+		CgroupMemoryUsage: math.NaN(),
+	}
+
+	if err := readProcStatus(filepath.Join(dir, "status"), status); err != nil {
+		return nil, err
+	}
+
+	// The file descriptors of a process of another user are readable only by root.
+	if fds, err := os.ReadDir(filepath.Join(dir, "fd")); err != nil {
+		c.base.logger.Warnf("Cannot read file descriptors of process %d: %v", pid, err)
+	} else {
+		status.OpenFDs = float64(len(fds))
+	}
+
+	if status.MaxFDs, err = readMaxOpenFiles(filepath.Join(dir, "limits")); err != nil {
+		c.base.logger.Warnf("Cannot read limits of process %d: %v", pid, err)
+	}
+
+	if err := readCgroupMemory(filepath.Join(dir, "cgroup"), status); err != nil {
+		c.base.logger.Debugf("Cannot read cgroup memory of process %d: %v", pid, err)
+	}
+
+	return status, nil
+}
+
+// mongoProcessDir returns the /proc directory of the MongoDB process of the PID. The PID of serverStatus
+// is of another process if MongoDB runs in another PID namespace, e.g. a container.
+func mongoProcessDir(pid int64) (string, error) {
+	dir := filepath.Join(procDir, strconv.FormatInt(pid, 10))
+
+	comm, err := os.ReadFile(filepath.Join(dir, "comm"))
+	if err != nil {
+		return "", fmt.Errorf("failed to read process %d: %w", pid, err)
+	}
+	if name := strings.TrimSpace(string(comm)); name != "mongod" && name != "mongos" {
+		return "", fmt.Errorf("process %d is %s, not MongoDB, which may run in another PID namespace", pid, name)
+	}
+
+	return dir, nil
+}
+
+// readProcStatus reads the resident memory, threads and context switches of /proc/<pid>/status.
+func readProcStatus(path string, status *metric.ProcessStatus) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// VmRSS:	  123456 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+
+		v, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			continue
+		}
+
+		switch fields[0] {
+		case "VmRSS:":
+			status.ResidentMemory = v * 1024
+		case "Threads:":
+			status.Threads = v
+		case "voluntary_ctxt_switches:":
+			status.VoluntaryCtxtSwitches = v
+		case "nonvoluntary_ctxt_switches:":
+			status.NonvoluntaryCtxtSwitches = v
+		}
+	}
+
+	return scanner.Err()
+}
+
+// readMaxOpenFiles returns the soft limit of the open files of /proc/<pid>/limits.
+func readMaxOpenFiles(path string) (float64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return math.NaN(), err
+	}
+
+	for _, line := range strings.Split(string(content), "\n") {
+		// Max open files            64000                64000                files
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 || fields[0] == "unlimited" {
+			return math.NaN(), nil
+		}
+
+		return strconv.ParseFloat(fields[0], 64)
+	}
+
+	return math.NaN(), errors.New("no limit of open files")
+}
+
+// readCgroupMemory reads the memory limit and usage of the cgroup of /proc/<pid>/cgroup,
+// from the memory controller of cgroup v1 if it is mounted, or from the unified hierarchy of cgroup v2.
+func readCgroupMemory(path string, status *metric.ProcessStatus) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var v1Path, v2Path string
+	for _, line := range strings.Split(string(content), "\n") {
+		// 4:memory:/system.slice/mongod.service or 0::/system.slice/mongod.service
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+
+		if parts[0] == "0" && parts[1] == "" {
+			v2Path = parts[2]
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			if controller == "memory" {
+				v1Path = parts[2]
+			}
+		}
+	}
+
+	var limitFile, usageFile string
+	switch {
+	case v1Path != "":
+		limitFile, usageFile = cgroupFiles(filepath.Join(cgroupDir, "memory"), v1Path, "memory.limit_in_bytes", "memory.usage_in_bytes")
+	case v2Path != "":
+		limitFile, usageFile = cgroupFiles(cgroupDir, v2Path, "memory.max", "memory.current")
+	default:
+		return errors.New("no memory cgroup")
+	}
+
+	if limit, err := readCgroupValue(limitFile); err != nil {
+		return err
+	} else if limit < cgroupV1Unlimited {
+		status.CgroupMemoryLimit = limit
+	}
+
+	if status.CgroupMemoryUsage, err = readCgroupValue(usageFile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// cgroupFiles returns the files of the cgroup. Inside a container, the cgroup of /proc/<pid>/cgroup
+// is of the host while its root is mounted on the hierarchy, so the root is used if the cgroup is not found.
+func cgroupFiles(hierarchy, cgroup, limit, usage string) (string, string) {
+	dir := filepath.Join(hierarchy, cgroup)
+	if _, err := os.Stat(filepath.Join(dir, limit)); err != nil {
+		dir = hierarchy
+	}
+
+	return filepath.Join(dir, limit), filepath.Join(dir, usage)
+}
+
+// readCgroupValue reads a number of bytes of a cgroup file, +Inf for "max" of cgroup v2.
+func readCgroupValue(path string) (float64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return math.NaN(), err
+	}
+
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return math.Inf(1), nil
+	}
+
+	return strconv.ParseFloat(value, 64)
+}
@@ -0,0 +1,293 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// CollectorParams is given to a CollectorFactory on every collection.
+type CollectorParams struct {
+	// Ctx is done at the deadline of the scrape, or of the collection in background mode.
+	Ctx    context.Context
+	Client *mongo.Client
+	Logger *logrus.Logger
+	// Opts must not be modified.
+	Opts *Opts
+
+	base *baseCollector
+}
+
+// NewCollector returns a collector which runs collect once per collection. The error returned by
+// collect is reported by the collector self-metrics such as mobserver_collector_success.
+func (p *CollectorParams) NewCollector(collect func(ch chan<- prometheus.Metric) error) prometheus.Collector {
+	return &funcCollector{
+		base:    p.base,
+		collect: collect,
+	}
+}
+
+// MatchNamespace reports whether the namespace of the form <database>.<collection> is selected
+// by the namespace filters of the options. Per-namespace collectors should skip the others.
+func (p *CollectorParams) MatchNamespace(ns string) bool {
+	return p.Opts.nsFilter.Match(ns)
+}
+
+// LimitNamespaces returns the namespaces to export separately, the ones with the highest activity
+// within the namespace limit of the collector. The metrics of the others should be aggregated into
+// the namespace metric.OtherNamespace, and their number reported by NamespacesDropped. If cumulative
+// is set, activity is a counter, and the namespaces are ranked by its increase since the previous collection.
+func (p *CollectorParams) LimitNamespaces(activity map[string]float64, cumulative bool) map[string]bool {
+	return p.base.limitNamespaces(activity, cumulative)
+}
+
+// NamespacesDropped returns the metrics of the number of namespaces aggregated by the collector.
+func (p *CollectorParams) NamespacesDropped(dropped int) []prometheus.Metric {
+	return p.base.namespacesDropped(dropped)
+}
+
+// CollectorFactory builds a collector for a single collection.
+type CollectorFactory func(params *CollectorParams) prometheus.Collector
+
+// CollectorOption describes where a registered collector can run.
+type CollectorOption func(spec *collectorSpec)
+
+// WithHelp sets the description of the collector shown in the help of the flags.
+func WithHelp(help string) CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.help = help
+	}
+}
+
+// NotOnMongos disables the collector when the server is a mongos.
+func NotOnMongos() CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.notOnMongos = true
+	}
+}
+
+// NotOnArbiter disables the collector when the server is an arbiter.
+func NotOnArbiter() CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.notOnArbiter = true
+	}
+}
+
+// OnlyOnConfigServer disables the collector unless the server is a config server.
+func OnlyOnConfigServer() CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.onlyOnConfigServer = true
+	}
+}
+
+// OnlyOnReplicaSet disables the collector unless the server is a member of a replica set.
+func OnlyOnReplicaSet() CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.onlyOnReplicaSet = true
+	}
+}
+
+// OnlyOnLocalhost disables the collector unless MongoDB runs on the same host,
+// because the collector inspects the local host.
+func OnlyOnLocalhost() CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.onlyOnLocalhost = true
+	}
+}
+
+// RequireCommands disables the collector unless the commands are found in PATH.
+func RequireCommands(cmds ...string) CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.requiredCommands = append(spec.requiredCommands, cmds...)
+	}
+}
+
+// RequirePrivileges declares the privileges the collector needs, which are verified by Exporter.Check.
+func RequirePrivileges(privileges ...Privilege) CollectorOption {
+	return func(spec *collectorSpec) {
+		spec.requiredPrivileges = append(spec.requiredPrivileges, privileges...)
+	}
+}
+
+// collectorSpec describes a registered collector.
+type collectorSpec struct {
+	name    string
+	help    string
+	factory CollectorFactory
+
+	notOnMongos        bool
+	notOnArbiter       bool
+	onlyOnConfigServer bool
+	onlyOnReplicaSet   bool
+	onlyOnLocalhost    bool
+	requiredCommands   []string
+	requiredPrivileges []Privilege
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   []*collectorSpec
+)
+
+// RegisterCollector makes a collector available by the name. Collectors are enabled by the name
+// in Opts.Collectors, selected by collect[] parameters of a scrape and enabled all by Opts.CollectAll.
+// It panics if the name is already registered, and it should be called before the exporter is created.
+func RegisterCollector(name string, factory CollectorFactory, opts ...CollectorOption) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	for _, spec := range registry {
+		if spec.name == name {
+			panic(fmt.Sprintf("exporter: collector %s is already registered", name))
+		}
+	}
+
+	spec := &collectorSpec{
+		name:    name,
+		factory: factory,
+	}
+	for _, opt := range opts {
+		opt(spec)
+	}
+
+	registry = append(registry, spec)
+}
+
+// CollectorNames returns the names of the registered collectors in sorted order.
+func CollectorNames() []string {
+	names := []string{}
+	for _, spec := range registeredCollectors() {
+		names = append(names, spec.name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// CollectorHelp returns the description of the registered collector.
+func CollectorHelp(name string) string {
+	if spec := lookupCollector(name); spec != nil {
+		return spec.help
+	}
+
+	return ""
+}
+
+// registeredCollectors returns the registered collectors in the order of registration.
+func registeredCollectors() []*collectorSpec {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	specs := make([]*collectorSpec, len(registry))
+	copy(specs, registry)
+
+	return specs
+}
+
+// build creates the collector on the base, which reports the result of the collection.
+func (s *collectorSpec) build(opts *Opts, base *baseCollector) prometheus.Collector {
+	return s.factory(&CollectorParams{
+		Ctx:    base.ctx,
+		Client: base.client,
+		Logger: base.logger,
+		Opts:   opts,
+		base:   base,
+	})
+}
+
+func lookupCollector(name string) *collectorSpec {
+	for _, spec := range registeredCollectors() {
+		if spec.name == name {
+			return spec
+		}
+	}
+
+	return nil
+}
+
+// funcCollector is a collector built by CollectorParams.NewCollector.
+type funcCollector struct {
+	base    *baseCollector
+	collect func(ch chan<- prometheus.Metric) error
+}
+
+func (c *funcCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *funcCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func init() {
+	RegisterCollector("replicasetstatus", func(p *CollectorParams) prometheus.Collector {
+		return newReplicationStatusCollector(p.base, p.Opts.isMongos)
+	}, WithHelp("Enable collecting metrics from replSetGetStatus"),
+		RequirePrivileges(ClusterPrivilege("replSetGetStatus"), ClusterPrivilege("replSetGetConfig")))
+
+	RegisterCollector("topmetrics", func(p *CollectorParams) prometheus.Collector {
+		return newTopCollector(p.base, p.Opts.nsFilter)
+	}, WithHelp("Enable collecting metrics from top admin command"), NotOnMongos(), NotOnArbiter(),
+		RequirePrivileges(ClusterPrivilege("top")))
+
+	RegisterCollector("currentopmetrics", func(p *CollectorParams) prometheus.Collector {
+		return newCurrentOpCollector(p.base, p.Opts.SlowQueryThresholdMS, p.Opts.systemNsFilter)
+	}, WithHelp("Enable collecting metrics currentop admin command"), NotOnMongos(), NotOnArbiter(),
+		RequirePrivileges(ClusterPrivilege("inprog")))
+
+	RegisterCollector("oplogstats", func(p *CollectorParams) prometheus.Collector {
+		return newOplogCollector(p.base)
+	}, WithHelp("Enable collecting metrics from oplog"), NotOnMongos(), NotOnArbiter(),
+		RequirePrivileges(CollectionPrivilege("local", "oplog.rs", "collStats"), CollectionPrivilege("local", "oplog.rs", "find")))
+
+	RegisterCollector("lvmsnapshotstats", func(p *CollectorParams) prometheus.Collector {
+		return newSnapshotCollector(p.base, p.Opts.SnapshotBackend, p.Opts.LVMSnapshotBackupDir)
+	}, WithHelp("Enable collecting metrics of the filesystem snapshots from lvs, zfs or btrfs"), NotOnMongos(),
+		NotOnArbiter(), OnlyOnLocalhost())
+
+	RegisterCollector("hostsettings", func(p *CollectorParams) prometheus.Collector {
+		return newHostSettingsCollector(p.base, p.Opts.isMongos)
+	}, WithHelp("Enable checking the kernel settings of the host against the production notes of MongoDB"),
+		OnlyOnLocalhost(), RequirePrivileges(ClusterPrivilege("serverStatus"), ClusterPrivilege("getCmdLineOpts")))
+
+	RegisterCollector("backupstats", func(p *CollectorParams) prometheus.Collector {
+		return newBackupCollector(p.base, p.Opts.LVMSnapshotBackupDir, p.Opts.BackupMarker)
+	}, WithHelp("Enable collecting metrics of the backup files in the backup directory"), NotOnMongos(),
+		NotOnArbiter(), OnlyOnLocalhost())
+
+	RegisterCollector("diskstats", func(p *CollectorParams) prometheus.Collector {
+		return newDiskCollector(p.base, p.Opts.isMongos, p.Opts.LVMSnapshotBackupDir)
+	}, WithHelp("Enable collecting metrics of the filesystems of dbPath, journal, log and backup directories"),
+		OnlyOnLocalhost(), RequirePrivileges(ClusterPrivilege("getCmdLineOpts")))
+
+	RegisterCollector("processstats", func(p *CollectorParams) prometheus.Collector {
+		return newProcessCollector(p.base)
+	}, WithHelp("Enable collecting metrics of the MongoDB process from /proc"), OnlyOnLocalhost(),
+		RequirePrivileges(ClusterPrivilege("serverStatus")))
+
+	RegisterCollector("rollbackstats", func(p *CollectorParams) prometheus.Collector {
+		return newRollbackCollector(p.base, p.Opts.nsFilter)
+	}, WithHelp("Enable collecting metrics from rollback"), NotOnMongos(), NotOnArbiter(), OnlyOnLocalhost(),
+		RequirePrivileges(ClusterPrivilege("getCmdLineOpts"), ClusterPrivilege("listDatabases"),
+			DatabasePrivilege("", "listCollections")))
+
+	RegisterCollector("replevents", func(p *CollectorParams) prometheus.Collector {
+		return newReplEventCollector(p.base)
+	}, WithHelp("Enable counting rollbacks, elections and step-downs from serverStatus and replSetGetStatus"),
+		NotOnMongos(), OnlyOnReplicaSet(),
+		RequirePrivileges(ClusterPrivilege("serverStatus"), ClusterPrivilege("replSetGetStatus")))
+
+	RegisterCollector("shardstats", func(p *CollectorParams) prometheus.Collector {
+		return newShardingStatsCollector(p.base, p.Opts.systemNsFilter)
+	}, WithHelp("Enable collecting metrics from shard"), NotOnArbiter(), OnlyOnConfigServer(),
+		RequirePrivileges(DatabasePrivilege("config", "find")))
+
+	RegisterCollector("instance", func(p *CollectorParams) prometheus.Collector {
+		return newInstanceCollector(p.base)
+	}, WithHelp("Enable collecting metrics from buildInfo"))
+}
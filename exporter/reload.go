@@ -0,0 +1,108 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const disconnectTimeout = 10 * time.Second
+
+// Reload validates opts and replaces the options and the client of the exporter with them.
+// The exporter keeps serving with the previous ones if the options are invalid. The server is
+// detected with the new options, or on the next collection if MongoDB is unavailable, as on startup.
+func (e *Exporter) Reload(ctx context.Context, opts *Opts) error {
+	if opts.Logger == nil {
+		opts.Logger = e.logger
+	}
+
+	probeOpts := *opts
+
+	if err := validateOpts(opts); err != nil {
+		return fmt.Errorf("failed to validate options: %w", err)
+	}
+
+	// Detect the server with a client of the new options, so that the current one keeps serving.
+	next := &Exporter{
+		logger: e.logger,
+		opts:   opts,
+		lock:   &sync.Mutex{},
+	}
+
+	var topology *Opts
+	client, err := next.getClient(ctx)
+	if err != nil {
+		e.logger.Warnf("Cannot connect to MongoDB, detecting the server on the next collection: %v", err)
+	} else {
+		var reasons map[string]string
+		topology, reasons, err = detectTopology(ctx, client, opts)
+		if err != nil {
+			e.logger.Warnf("Cannot detect MongoDB server, detecting it on the next collection: %v", err)
+		} else {
+			logTopologyChanges(e.logger, nil, topology, reasons)
+		}
+	}
+
+	e.lock.Lock()
+	e.opts = opts
+	e.topology = topology
+	e.detectedAt = time.Now()
+	e.probeOpts = probeOpts
+	background := e.stopBackground != nil
+	e.lock.Unlock()
+
+	e.clientMu.Lock()
+	prev := e.client
+	e.client = nil
+	if opts.GlobalConnPool {
+		e.client = client
+	}
+	e.clientMu.Unlock()
+
+	if prev != nil {
+		disconnect(prev, opts)
+	}
+	if client != nil && !opts.GlobalConnPool {
+		disconnect(client, opts)
+	}
+
+	e.resetTargets()
+
+	if opts.BackgroundCollection {
+		// Bound by the lifetime of the exporter, not by the request of the reload.
+		e.StartBackgroundCollection(e.ctx)
+	} else if background {
+		e.StopBackgroundCollection()
+	}
+
+	return nil
+}
+
+// resetTargets drops the probe targets, which are created again with the current options.
+func (e *Exporter) resetTargets() {
+	e.targetsMu.Lock()
+	targets := e.targets
+	e.targets = make(map[string]*probeTarget)
+	e.targetsMu.Unlock()
+
+	for _, t := range targets {
+		t.lock.Lock()
+		t.close()
+		t.lock.Unlock()
+	}
+}
+
+// disconnect closes the client in the background, after the operations in progress complete.
+func disconnect(client *mongo.Client, opts *Opts) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), disconnectTimeout)
+		defer cancel()
+
+		if err := client.Disconnect(ctx); err != nil {
+			opts.Logger.Errorf("Cannot disconnect client: %v", err)
+		}
+	}()
+}
@@ -2,13 +2,12 @@ package exporter
 
 import (
 	"context"
+	"errors"
 	"mobserver/internal/metric"
 	"mobserver/internal/model"
 	"mobserver/internal/mongoutils"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type replicationStatusCollector struct {
@@ -17,10 +16,10 @@ type replicationStatusCollector struct {
 	isMongos bool
 }
 
-func newReplicationStatusCollector(client *mongo.Client, logger *logrus.Logger, isMongos bool) prometheus.Collector {
+func newReplicationStatusCollector(base *baseCollector, isMongos bool) prometheus.Collector {
 	return &replicationStatusCollector{
-		ctx:      context.Background(),
-		base:     newBaseCollector(client, logger),
+		ctx:      base.ctx,
+		base:     base,
 		isMongos: isMongos,
 	}
 }
@@ -33,19 +32,19 @@ func (c *replicationStatusCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *replicationStatusCollector) collect(ch chan<- prometheus.Metric) {
+func (c *replicationStatusCollector) collect(ch chan<- prometheus.Metric) error {
 	if c.isMongos {
 		// mongos
 		for _, mt := range metric.NewRoleMetrics(model.MongoReplRoleType(-1)) {
 			ch <- mt
 		}
-		return
+		return nil
 	}
 
 	replStatus, err := mongoutils.GetReplStatus(c.ctx, c.base.client)
 	if err != nil {
 		c.base.logger.Errorf("Failed to get replication status: %v", err)
-		return
+		return err
 	}
 	for _, mt := range metric.NewRoleMetrics(replStatus.MyState) {
 		ch <- mt
@@ -54,20 +53,22 @@ func (c *replicationStatusCollector) collect(ch chan<- prometheus.Metric) {
 	replConfig, err := mongoutils.GetReplConfig(c.ctx, c.base.client)
 	if err != nil {
 		c.base.logger.Errorf("Failed to get replication config: %v", err)
-		return
+		return err
 	}
 
 	if replStatus == nil || replConfig == nil {
-		return
+		return nil
 	}
 
 	rsMt := metric.NewReplStatus(replStatus, replConfig)
 	if rsMt == nil {
 		c.base.logger.Errorf("Failed to find self member in replication status")
-		return
+		return errors.New("self member not found in replication status")
 	}
 
 	for _, mt := range rsMt.ToPromMetrics() {
 		ch <- mt
 	}
+
+	return nil
 }
@@ -0,0 +1,80 @@
+package exporter
+
+import (
+	"context"
+	"mobserver/internal/metric"
+	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// replEventCollector counts the rollbacks, elections and step-downs with commands, so that unlike
+// rollbackstats it works on remote members too.
+type replEventCollector struct {
+	ctx  context.Context
+	base *baseCollector
+}
+
+func newReplEventCollector(base *baseCollector) prometheus.Collector {
+	return &replEventCollector{
+		ctx:  base.ctx,
+		base: base,
+	}
+}
+
+func (c *replEventCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *replEventCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *replEventCollector) collect(ch chan<- prometheus.Metric) error {
+	serverStatus, err := mongoutils.GetServerStatus(c.ctx, c.base.client)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get server status: %v", err)
+		return err
+	}
+
+	replStatus, err := mongoutils.GetReplStatus(c.ctx, c.base.client)
+	if err != nil {
+		c.base.logger.Errorf("Failed to get replication status: %v", err)
+		return err
+	}
+
+	sample := metric.ReplEventSample{
+		RBID:   -1,
+		Term:   replStatus.Term,
+		States: make(map[string]model.MongoReplRoleType, len(replStatus.Members)),
+	}
+	if serverStatus.Repl.RBID != nil {
+		sample.RBID = *serverStatus.Repl.RBID
+	}
+	for _, member := range replStatus.Members {
+		sample.States[member.Name] = member.State
+		if member.State == model.REPL_PRIMARY {
+			sample.Primary = member.Name
+		}
+	}
+
+	events := c.base.replEvents.Observe(sample)
+	for _, mt := range events.ToPromMetrics() {
+		ch <- mt
+	}
+
+	return nil
+}
+
+// replEventTracker returns the tracker of the exporter, which keeps the state of the previous collection.
+func (e *Exporter) replEventTracker() *metric.ReplEventTracker {
+	e.replEventsMu.Lock()
+	defer e.replEventsMu.Unlock()
+
+	if e.replEvents == nil {
+		e.replEvents = metric.NewReplEventTracker()
+	}
+
+	return e.replEvents
+}
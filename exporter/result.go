@@ -0,0 +1,64 @@
+package exporter
+
+import (
+	"context"
+	"mobserver/internal/metric"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+func newCollectorErrors() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mobserver_collector_errors_total",
+		Help: "Number of failed collections by the class of error (auth, timeout, network, decode, connection, detection, other)",
+	}, []string{"collector", "class"})
+}
+
+// observeResult counts the error of a collection if it failed. The server is detected again on
+// the next collection if the error may come from a change of the connection or of its role, but not
+// for the errors of the collector itself, such as a missing privilege, which would otherwise cost
+// a detection on every collection.
+func (e *Exporter) observeResult(name string, res collectResult) {
+	if res.err == nil {
+		return
+	}
+
+	class := errorClass(res.err)
+	e.collectorErrors.WithLabelValues(name, class).Inc()
+
+	if class == "network" || class == "connection" || isRoleChangeError(res.err) {
+		e.invalidateTopology()
+	}
+}
+
+// resultCollector exports the duration and success of the collections.
+type resultCollector struct {
+	base    *baseCollector
+	results map[string]collectResult
+}
+
+func newResultCollector(logger *logrus.Logger, results map[string]collectResult) prometheus.Collector {
+	return &resultCollector{
+		base:    newBaseCollector(context.Background(), "", nil, logger),
+		results: results,
+	}
+}
+
+func (c *resultCollector) Describe(ch chan<- *prometheus.Desc) {
+	c.base.Describe(ch, c.collect)
+}
+
+func (c *resultCollector) Collect(ch chan<- prometheus.Metric) {
+	c.base.Collect(ch)
+}
+
+func (c *resultCollector) collect(ch chan<- prometheus.Metric) error {
+	for name, res := range c.results {
+		for _, mt := range metric.CollectorResultToPromMetrics(name, res.duration.Seconds(), res.err == nil) {
+			ch <- mt
+		}
+	}
+
+	return nil
+}
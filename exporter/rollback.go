@@ -1,28 +1,34 @@
 package exporter
 
 import (
-	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"mobserver/internal/metric"
 	"mobserver/internal/mongoutils"
-	"os/exec"
-	"strings"
+	"os"
+	"path/filepath"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// unknownNamespace is the namespace of the rollback files whose collection UUID is not found,
+// e.g. because the collection was dropped after the rollback.
+const unknownNamespace = "unknown.unknown"
+
 type rollbackCollector struct {
 	ctx  context.Context
 	base *baseCollector
+
+	nsFilter *metric.NamespaceFilter
 }
 
-func newRollbackCollector(client *mongo.Client, logger *logrus.Logger) prometheus.Collector {
+func newRollbackCollector(base *baseCollector, nsFilter *metric.NamespaceFilter) prometheus.Collector {
 	return &rollbackCollector{
-		ctx:  context.Background(),
-		base: newBaseCollector(client, logger),
+		ctx:      base.ctx,
+		base:     base,
+		nsFilter: nsFilter,
 	}
 }
 
@@ -34,69 +40,100 @@ func (c *rollbackCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *rollbackCollector) collect(ch chan<- prometheus.Metric) {
-	// Rollback info represents a map of collection UUIDs
-	// to a boolean value indicating whether a rollback is in progress.
+func (c *rollbackCollector) collect(ch chan<- prometheus.Metric) error {
+	// Rollback info represents a map of namespaces to their rollback files.
 	rollbackInfo, err := c.getRollbackStatus()
 	if err != nil {
 		c.base.logger.Errorf("Failed to get rollback status: %v", err)
-		return
+		return err
 	}
 
 	for _, mt := range metric.RollbackStatusToPromMetrics(rollbackInfo) {
 		ch <- mt
 	}
-}
 
-func (c *rollbackCollector) getRollbackStatus() (map[string]bool, error) {
-	var out bytes.Buffer
-	var stderr bytes.Buffer
+	return nil
+}
 
+// getRollbackStatus reads the rollback files, which MongoDB writes in <dbPath>/rollback/<collection UUID>/,
+// and returns them by namespace.
+func (c *rollbackCollector) getRollbackStatus() (map[string]*metric.RollbackFiles, error) {
 	cmdLineOpts, err := mongoutils.GetCmdLineOpts(c.ctx, c.base.client)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get command line options: %v", err)
+		return nil, fmt.Errorf("failed to get command line options: %w", err)
 	}
 
-	rollbackDir := cmdLineOpts.Parsed.Storage.DBPath + "/rollback"
+	rollbackDir := filepath.Join(cmdLineOpts.Parsed.Storage.DBPath, "rollback")
 
-	res := make(map[string]bool)
+	res := make(map[string]*metric.RollbackFiles)
 
-	cmd := exec.Command("bash", "-c", fmt.Sprintf("[ -d %s ]", rollbackDir))
-	if err := cmd.Run(); err != nil {
-		// If the rollback directory does not exist, there is no rollback in progress.
+	entries, err := os.ReadDir(rollbackDir)
+	if os.IsNotExist(err) {
+		// If the rollback directory does not exist, there has been no rollback.
 		return res, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read rollback directory: %w", err)
 	}
 
-	cmd = exec.Command("bash", "-c", fmt.Sprintf("ls -l %s | grep -v total | awk '{print $NF}'", rollbackDir))
-	cmd.Stdout = &out
-	cmd.Stderr = &stderr
+	var collInfo map[string]string
+	now := time.Now()
 
-	if err := cmd.Run(); err != nil {
-		return nil, fmt.Errorf("failed to get rollback status: %v", stderr.String())
-	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
 
-	collUUIDs := strings.Split(out.String(), "\n")
-	if len(collUUIDs) == 0 {
-		return res, nil
+		if collInfo == nil {
+			collInfo, err = mongoutils.GetAllDatabasesAndCollections(c.ctx, c.base.client)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get collection UUIDs: %w", err)
+			}
+		}
+
+		uid := entry.Name()
+		ns, ok := collInfo[uid]
+		if !ok {
+			c.base.logger.Warnf("Unknown collection UUID: %s", uid)
+			ns = unknownNamespace
+		} else if !c.nsFilter.Match(ns) {
+			continue
+		}
+
+		files, ok := res[ns]
+		if !ok {
+			files = &metric.RollbackFiles{NewestAge: math.NaN()}
+			res[ns] = files
+		}
+
+		if err := addRollbackFiles(files, filepath.Join(rollbackDir, uid), now); err != nil {
+			return nil, err
+		}
 	}
 
-	collInfo, err := mongoutils.GetAllDatabasesAndCollections(c.ctx, c.base.client)
+	return res, nil
+}
+
+// addRollbackFiles adds the regular files of the rollback directory of a collection.
+func addRollbackFiles(files *metric.RollbackFiles, dir string, now time.Time) error {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get collection UUIDs: %v", err)
+		return fmt.Errorf("failed to read rollback directory: %w", err)
 	}
 
-	for _, uid := range collUUIDs {
-		if uid == "" {
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
 			continue
 		}
 
-		if ns, ok := collInfo[uid]; !ok {
-			c.base.logger.Warnf("Unknown collection UUID: %s", uid)
-			res["unknown.unknown"] = true
-		} else {
-			res[ns] = true
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to stat rollback file: %w", err)
 		}
+
+		files.AddFile(float64(info.Size()), now.Sub(info.ModTime()).Seconds())
 	}
 
-	return res, nil
+	return nil
 }
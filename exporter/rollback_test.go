@@ -0,0 +1,135 @@
+package exporter
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	opReply = 1
+	opQuery = 2004
+	opMsg   = 2013
+)
+
+// dropCommandsServer is a fake server which answers the handshakes and the heartbeats, and drops
+// the connection on any other command, so that the commands fail with a network error.
+func dropCommandsServer(t *testing.T) string {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	t.Cleanup(func() { l.Close() }) //nolint:errcheck
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveDropCommands(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func serveDropCommands(conn net.Conn) {
+	defer conn.Close() //nolint:errcheck
+
+	hello, _ := bson.Marshal(bson.D{
+		{Key: "ismaster", Value: true},
+		{Key: "isWritablePrimary", Value: true},
+		{Key: "minWireVersion", Value: 0},
+		{Key: "maxWireVersion", Value: 13},
+		{Key: "maxBsonObjectSize", Value: 16 * 1024 * 1024},
+		{Key: "maxMessageSizeBytes", Value: 48000000},
+		{Key: "maxWriteBatchSize", Value: 100000},
+		{Key: "ok", Value: 1},
+	})
+
+	for {
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		body := make([]byte, binary.LittleEndian.Uint32(header)-16)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		requestID := binary.LittleEndian.Uint32(header[4:])
+
+		var doc bson.Raw
+		var reply []byte
+		switch binary.LittleEndian.Uint32(header[12:]) {
+		case opQuery:
+			// flags, full collection name, numberToSkip, numberToReturn, query
+			name := 4
+			for body[name] != 0 {
+				name++
+			}
+			doc = bson.Raw(body[name+9:])
+			reply = append(make([]byte, 20), hello...)
+			binary.LittleEndian.PutUint32(reply[16:], 1)
+			reply = wireMessage(requestID, opReply, reply)
+		case opMsg:
+			// flagBits, kind 0 section
+			doc = bson.Raw(body[5:])
+			reply = wireMessage(requestID, opMsg, append(make([]byte, 5), hello...))
+		default:
+			return
+		}
+
+		command := doc.Index(0).Key()
+		if command != "hello" && command != "isMaster" && command != "ismaster" {
+			return
+		}
+		if _, err := conn.Write(reply); err != nil {
+			return
+		}
+	}
+}
+
+func wireMessage(responseTo uint32, opCode uint32, body []byte) []byte {
+	msg := make([]byte, 16, 16+len(body))
+	binary.LittleEndian.PutUint32(msg, uint32(16+len(body)))
+	binary.LittleEndian.PutUint32(msg[8:], responseTo)
+	binary.LittleEndian.PutUint32(msg[12:], opCode)
+
+	return append(msg, body...)
+}
+
+func TestRollbackNetworkErrorClass(t *testing.T) {
+	addr := dropCommandsServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://"+addr).SetDirect(true).SetRetryReads(false))
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer client.Disconnect(ctx) //nolint:errcheck
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	c := newRollbackCollector(newBaseCollector(ctx, "rollbackstats", client, logger), nil).(*rollbackCollector)
+
+	_, err = c.getRollbackStatus()
+	if err == nil {
+		t.Fatal("getRollbackStatus() error = nil, want a network error")
+	}
+	if class := errorClass(err); class != "network" {
+		t.Errorf("errorClass(%v) = %q, want %q", err, class, "network")
+	}
+}
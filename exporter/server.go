@@ -1,6 +1,7 @@
 package exporter
 
 import (
+	"fmt"
 	"net/http"
 	"os"
 	"time"
@@ -13,8 +14,12 @@ import (
 // ServerOpts is the options for the main http handler
 type ServerOpts struct {
 	Path             string
+	ProbePath        string
 	WebListenAddress string
 	TLSConfigPath    string
+
+	// Reload reloads the configuration on POST /-/reload if it is set.
+	Reload func() error
 }
 
 // Runs the main web-server
@@ -23,6 +28,26 @@ func RunWebServer(opts *ServerOpts, exporter *Exporter, log *logrus.Logger) {
 
 	mux.Handle(opts.Path, exporter.Handler())
 
+	if opts.ProbePath != "" {
+		mux.Handle(opts.ProbePath, exporter.ProbeHandler())
+	}
+
+	mux.Handle("/-/healthy", exporter.HealthyHandler())
+	mux.Handle("/-/ready", exporter.ReadyHandler())
+
+	if opts.Reload != nil {
+		mux.HandleFunc("/-/reload", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost && r.Method != http.MethodPut {
+				http.Error(w, "This endpoint requires a POST or PUT request.", http.StatusMethodNotAllowed)
+				return
+			}
+
+			if err := opts.Reload(); err != nil {
+				http.Error(w, fmt.Sprintf("failed to reload config: %v", err), http.StatusInternalServerError)
+			}
+		})
+	}
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		_, err := w.Write([]byte(`<html>
             <head><title>Mobserver</title></head>
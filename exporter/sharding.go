@@ -4,24 +4,26 @@ import (
 	"context"
 	"mobserver/internal/metric"
 	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type shardingStatsCollector struct {
 	ctx  context.Context
 	base *baseCollector
+
+	nsFilter *metric.NamespaceFilter
 }
 
-func newShardingStatsCollector(client *mongo.Client, logger *logrus.Logger) prometheus.Collector {
+func newShardingStatsCollector(base *baseCollector, nsFilter *metric.NamespaceFilter) prometheus.Collector {
 	return &shardingStatsCollector{
-		ctx:  context.Background(),
-		base: newBaseCollector(client, logger),
+		ctx:      base.ctx,
+		base:     base,
+		nsFilter: nsFilter,
 	}
 }
 
@@ -33,12 +35,21 @@ func (c *shardingStatsCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) {
+func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) error {
 	res := metric.ShardingStats{}
 
+	// Every stat is collected even if the others fail, and the first error is returned.
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
 	total, draining, err := c.getShardStats()
 	if err != nil {
 		c.base.logger.Errorf("getShardStats() failed: %v", err)
+		fail(err)
 	} else {
 		res.Shards = float64(total)
 		res.DrainingShards = float64(draining)
@@ -47,6 +58,7 @@ func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) {
 	sharded, unsharded, err := c.getDatabases()
 	if err != nil {
 		c.base.logger.Errorf("getDatabases() failed: %v", err)
+		fail(err)
 	} else {
 		res.ShardedDatabases = float64(sharded)
 		res.UnshardedDatabases = float64(unsharded)
@@ -55,6 +67,7 @@ func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) {
 	balancerEnabled, err := c.getBalancerEnabled()
 	if err != nil {
 		c.base.logger.Errorf("getBalancerEnabled() failed: %v", err)
+		fail(err)
 	} else {
 		if balancerEnabled {
 			res.BalancerEnabled = 1
@@ -66,6 +79,7 @@ func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) {
 	chunks, err := c.getChunkStats()
 	if err != nil {
 		c.base.logger.Errorf("getChunkStats() failed: %v", err)
+		fail(err)
 	} else {
 		res.Chunks = chunks
 	}
@@ -73,18 +87,82 @@ func (c *shardingStatsCollector) collect(ch chan<- prometheus.Metric) {
 	chunkMoves, err := c.getLastChunkMoveStats()
 	if err != nil {
 		c.base.logger.Errorf("getLastChunkMoveStats() failed: %v", err)
+		fail(err)
 	} else {
 		res.LastMovedChunks = chunkMoves
 	}
 
+	dropped := c.limitNamespaces(&res)
+
 	for _, mt := range res.ToPromMetrics() {
 		ch <- mt
 	}
+
+	for _, mt := range c.base.namespacesDropped(dropped) {
+		ch <- mt
+	}
+
+	return firstErr
+}
+
+// limitNamespaces aggregates the chunks and the chunk moves of the namespaces over the limit
+// into metric.OtherNamespace, ranking the namespaces by their number of chunks.
+// It returns the number of aggregated namespaces.
+func (c *shardingStatsCollector) limitNamespaces(res *metric.ShardingStats) int {
+	activity := make(map[string]float64)
+	for _, chunk := range res.Chunks {
+		activity[chunk.Ns] += float64(chunk.NChunks)
+	}
+	for _, move := range res.LastMovedChunks {
+		if _, ok := activity[move.Ns]; !ok {
+			activity[move.Ns] = 0
+		}
+	}
+
+	selected := c.base.limitNamespaces(activity, false)
+	if len(selected) == len(activity) {
+		return 0
+	}
+
+	chunks := []model.ConfigChunk{}
+	otherChunks := make(map[string]int)
+	var shards []string
+	for _, chunk := range res.Chunks {
+		if selected[chunk.Ns] {
+			chunks = append(chunks, chunk)
+			continue
+		}
+		if _, ok := otherChunks[chunk.Shard]; !ok {
+			shards = append(shards, chunk.Shard)
+		}
+		otherChunks[chunk.Shard] += chunk.NChunks
+	}
+	for _, shard := range shards {
+		chunks = append(chunks, model.ConfigChunk{Ns: metric.OtherNamespace, Shard: shard, NChunks: otherChunks[shard]})
+	}
+
+	moves := []model.ConfigChunkMoves{}
+	var otherMoves int
+	for _, move := range res.LastMovedChunks {
+		if selected[move.Ns] {
+			moves = append(moves, move)
+		} else {
+			otherMoves += move.NChunks
+		}
+	}
+	if otherMoves > 0 {
+		moves = append(moves, model.ConfigChunkMoves{Ns: metric.OtherNamespace, NChunks: otherMoves})
+	}
+
+	res.Chunks = chunks
+	res.LastMovedChunks = moves
+
+	return len(activity) - len(selected)
 }
 
 func (c *shardingStatsCollector) getShardStats() (int, int, error) {
 	res := []model.ConfigShard{}
-	cursor, err := c.base.client.Database("config").Collection("shards").Find(c.ctx, bson.D{})
+	cursor, err := c.base.client.Database("config").Collection("shards").Find(c.ctx, bson.D{}, mongoutils.FindOptions(c.ctx))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -108,7 +186,7 @@ func (c *shardingStatsCollector) getShardStats() (int, int, error) {
 
 func (c *shardingStatsCollector) getDatabases() (int, int, error) {
 	res := []model.ConfigDatabase{}
-	cursor, err := c.base.client.Database("config").Collection("databases").Find(c.ctx, bson.D{})
+	cursor, err := c.base.client.Database("config").Collection("databases").Find(c.ctx, bson.D{}, mongoutils.FindOptions(c.ctx))
 	if err != nil {
 		return 0, 0, err
 	}
@@ -133,7 +211,7 @@ func (c *shardingStatsCollector) getDatabases() (int, int, error) {
 func (c *shardingStatsCollector) getBalancerEnabled() (bool, error) {
 	res := model.ConfigBalancerSettings{}
 	cmd := bson.D{{Key: "_id", Value: "balancer"}}
-	if err := c.base.client.Database("config").Collection("settings").FindOne(c.ctx, cmd).Decode(&res); err != nil {
+	if err := c.base.client.Database("config").Collection("settings").FindOne(c.ctx, cmd, mongoutils.FindOneOptions(c.ctx)).Decode(&res); err != nil {
 		return false, err
 	}
 
@@ -141,16 +219,13 @@ func (c *shardingStatsCollector) getBalancerEnabled() (bool, error) {
 }
 
 func (c *shardingStatsCollector) getChunkStats() ([]model.ConfigChunk, error) {
-	systemNsRegex := "^$|^admin.*|^local.*|^config.*|^.*system\\.buckets$|^.*system\\.profile$|^.*system\\.js$|^.*system\\.views$"
 	collInfo := []struct {
 		Ns        string              `bson:"_id"`
 		UUID      interface{}         `bson:"uuid"`
 		Timestamp primitive.Timestamp `bson:"timestamp"`
 	}{}
 
-	cursor, err := c.base.client.Database("config").Collection("collections").Find(c.ctx, bson.D{
-		{Key: "_id", Value: bson.D{{Key: "$not", Value: bson.D{{Key: "$regex", Value: systemNsRegex}}}}},
-	})
+	cursor, err := c.base.client.Database("config").Collection("collections").Find(c.ctx, bson.D{}, mongoutils.FindOptions(c.ctx))
 	if err != nil {
 		return nil, err
 	}
@@ -163,6 +238,10 @@ func (c *shardingStatsCollector) getChunkStats() ([]model.ConfigChunk, error) {
 	res := []model.ConfigChunk{}
 
 	for _, coll := range collInfo {
+		if !c.nsFilter.Match(coll.Ns) {
+			continue
+		}
+
 		tmpRes := []model.ConfigChunk{}
 
 		hasTimestamp := !coll.Timestamp.IsZero()
@@ -183,7 +262,7 @@ func (c *shardingStatsCollector) getChunkStats() ([]model.ConfigChunk, error) {
 			}}},
 		}
 
-		cursor, err := c.base.client.Database("config").Collection("chunks").Aggregate(c.ctx, cmd)
+		cursor, err := c.base.client.Database("config").Collection("chunks").Aggregate(c.ctx, cmd, mongoutils.AggregateOptions(c.ctx))
 		if err != nil {
 			return nil, err
 		}
@@ -222,15 +301,22 @@ func (c *shardingStatsCollector) getLastChunkMoveStats() ([]model.ConfigChunkMov
 		}}},
 	}
 
-	res := []model.ConfigChunkMoves{}
-	cursor, err := c.base.client.Database("config").Collection("changelog").Aggregate(c.ctx, cmd)
+	moves := []model.ConfigChunkMoves{}
+	cursor, err := c.base.client.Database("config").Collection("changelog").Aggregate(c.ctx, cmd, mongoutils.AggregateOptions(c.ctx))
 	if err != nil {
 		return nil, err
 	}
 
-	if err := cursor.All(c.ctx, &res); err != nil {
+	if err := cursor.All(c.ctx, &moves); err != nil {
 		return nil, err
 	}
 
+	res := []model.ConfigChunkMoves{}
+	for _, move := range moves {
+		if c.nsFilter.Match(move.Ns) {
+			res = append(res, move)
+		}
+	}
+
 	return res, nil
 }
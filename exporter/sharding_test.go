@@ -0,0 +1,122 @@
+package exporter
+
+import (
+	"context"
+	"mobserver/internal/metric"
+	"mobserver/internal/model"
+	"reflect"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestShardingLimitNamespaces(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		stats       metric.ShardingStats
+		wantChunks  []model.ConfigChunk
+		wantMoves   []model.ConfigChunkMoves
+		wantDropped int
+	}{
+		{
+			name:  "under the limit",
+			limit: 2,
+			stats: metric.ShardingStats{
+				Chunks: []model.ConfigChunk{
+					{Ns: "db.a", Shard: "rs0", NChunks: 3},
+					{Ns: "db.b", Shard: "rs1", NChunks: 1},
+				},
+				LastMovedChunks: []model.ConfigChunkMoves{{Ns: "db.b", NChunks: 1}},
+			},
+			wantChunks: []model.ConfigChunk{
+				{Ns: "db.a", Shard: "rs0", NChunks: 3},
+				{Ns: "db.b", Shard: "rs1", NChunks: 1},
+			},
+			wantMoves: []model.ConfigChunkMoves{{Ns: "db.b", NChunks: 1}},
+		},
+		{
+			name:  "aggregated by shard",
+			limit: 1,
+			stats: metric.ShardingStats{
+				Chunks: []model.ConfigChunk{
+					{Ns: "db.a", Shard: "rs0", NChunks: 2},
+					{Ns: "db.b", Shard: "rs0", NChunks: 4},
+					{Ns: "db.b", Shard: "rs1", NChunks: 4},
+					{Ns: "db.c", Shard: "rs1", NChunks: 1},
+					{Ns: "db.c", Shard: "rs0", NChunks: 1},
+				},
+				LastMovedChunks: []model.ConfigChunkMoves{
+					{Ns: "db.a", NChunks: 2},
+					{Ns: "db.b", NChunks: 1},
+					{Ns: "db.c", NChunks: 3},
+				},
+			},
+			wantChunks: []model.ConfigChunk{
+				{Ns: "db.b", Shard: "rs0", NChunks: 4},
+				{Ns: "db.b", Shard: "rs1", NChunks: 4},
+				{Ns: metric.OtherNamespace, Shard: "rs0", NChunks: 3},
+				{Ns: metric.OtherNamespace, Shard: "rs1", NChunks: 1},
+			},
+			wantMoves: []model.ConfigChunkMoves{
+				{Ns: "db.b", NChunks: 1},
+				{Ns: metric.OtherNamespace, NChunks: 5},
+			},
+			wantDropped: 2,
+		},
+		{
+			name:  "ties are broken by name",
+			limit: 1,
+			stats: metric.ShardingStats{
+				Chunks: []model.ConfigChunk{
+					{Ns: "db.b", Shard: "rs0", NChunks: 2},
+					{Ns: "db.a", Shard: "rs0", NChunks: 2},
+				},
+			},
+			wantChunks: []model.ConfigChunk{
+				{Ns: "db.a", Shard: "rs0", NChunks: 2},
+				{Ns: metric.OtherNamespace, Shard: "rs0", NChunks: 2},
+			},
+			wantMoves:   []model.ConfigChunkMoves{},
+			wantDropped: 1,
+		},
+		{
+			name:  "moved namespaces without chunks",
+			limit: 1,
+			stats: metric.ShardingStats{
+				Chunks: []model.ConfigChunk{{Ns: "db.a", Shard: "rs0", NChunks: 1}},
+				LastMovedChunks: []model.ConfigChunkMoves{
+					{Ns: "db.a", NChunks: 1},
+					{Ns: "db.b", NChunks: 2},
+				},
+			},
+			wantChunks: []model.ConfigChunk{{Ns: "db.a", Shard: "rs0", NChunks: 1}},
+			wantMoves: []model.ConfigChunkMoves{
+				{Ns: "db.a", NChunks: 1},
+				{Ns: metric.OtherNamespace, NChunks: 2},
+			},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base := newBaseCollector(context.Background(), "shardstats", nil, logrus.New())
+			base.nsLimit = tt.limit
+			c := &shardingStatsCollector{ctx: base.ctx, base: base}
+
+			stats := tt.stats
+			dropped := c.limitNamespaces(&stats)
+
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped = %d, want %d", dropped, tt.wantDropped)
+			}
+			if !reflect.DeepEqual(stats.Chunks, tt.wantChunks) {
+				t.Errorf("chunks = %+v, want %+v", stats.Chunks, tt.wantChunks)
+			}
+			if !reflect.DeepEqual(stats.LastMovedChunks, tt.wantMoves) {
+				t.Errorf("moves = %+v, want %+v", stats.LastMovedChunks, tt.wantMoves)
+			}
+		})
+	}
+}
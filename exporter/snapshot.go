@@ -2,28 +2,94 @@ package exporter
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"mobserver/internal/metric"
+	"os"
 	"os/exec"
-	"strconv"
 	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/sirupsen/logrus"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
+// The snapshot backends of the lvmsnapshotstats collector.
+const (
+	SnapshotBackendLVM   = "lvm"
+	SnapshotBackendZFS   = "zfs"
+	SnapshotBackendBtrfs = "btrfs"
+)
+
+// snapshotBackend lists the snapshots of a filesystem by pool.
+type snapshotBackend interface {
+	// command is the program the backend runs, which must be found in PATH.
+	command() string
+	// collect sends the metrics particular to the backend, and returns the snapshots of every pool.
+	collect(ctx context.Context, ch chan<- prometheus.Metric) ([]*metric.SnapshotPool, error)
+}
+
+// newSnapshotBackend returns the backend of the name, LVM if it is empty.
+// snapDir is the backup directory, on which the snapshots of btrfs are listed.
+func newSnapshotBackend(name, snapDir string, logger *logrus.Logger) (snapshotBackend, error) {
+	switch name {
+	case "", SnapshotBackendLVM:
+		return &lvmBackend{snapDir: snapDir, logger: logger}, nil
+	case SnapshotBackendZFS:
+		return &zfsBackend{}, nil
+	case SnapshotBackendBtrfs:
+		return &btrfsBackend{snapDir: snapDir, logger: logger}, nil
+	default:
+		return nil, ErrUnknownSnapshotBackend
+	}
+}
+
+// validateSnapshotOpts checks the snapshot backend, and disables the lvmsnapshotstats collector
+// if the command of the backend is not found in PATH.
+func validateSnapshotOpts(opts *Opts) error {
+	backend, err := newSnapshotBackend(opts.SnapshotBackend, opts.LVMSnapshotBackupDir, opts.Logger)
+	if err != nil {
+		return &ValidationError{Option: "SnapshotBackend", Value: opts.SnapshotBackend, Err: err}
+	}
+
+	if !opts.enabled["lvmsnapshotstats"] {
+		return nil
+	}
+
+	// The pools of ZFS are found without the backup directory.
+	if opts.LVMSnapshotBackupDir == "" && opts.SnapshotBackend != SnapshotBackendZFS {
+		return &ValidationError{Option: "LVMSnapshotBackupDir", Err: ErrBackupDirRequired}
+	}
+
+	if _, err := exec.LookPath(backend.command()); err != nil {
+		if !errors.Is(err, exec.ErrNotFound) {
+			return &ValidationError{Option: "Collectors", Value: "lvmsnapshotstats", Err: fmt.Errorf("failed to check for %s: %w", backend.command(), err)}
+		}
+		disableCollector(opts, "lvmsnapshotstats", fmt.Sprintf("%s is not found in PATH", backend.command()))
+	}
+
+	return nil
+}
+
 type snapshotCollector struct {
 	base *baseCollector
 
-	snapDir string
+	backendName string
+	backend     snapshotBackend
 }
 
-func newSnapshotCollector(client *mongo.Client, logger *logrus.Logger, snapDir string) prometheus.Collector {
+func newSnapshotCollector(base *baseCollector, backendName, snapDir string) prometheus.Collector {
+	// The backend is validated with the options.
+	backend, _ := newSnapshotBackend(backendName, snapDir, base.logger)
+	if backendName == "" {
+		backendName = SnapshotBackendLVM
+	}
+
 	return &snapshotCollector{
-		base: newBaseCollector(client, logger),
+		base: base,
 
-		snapDir: snapDir,
+		backendName: backendName,
+		backend:     backend,
 	}
 }
 
@@ -35,56 +101,45 @@ func (c *snapshotCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *snapshotCollector) collect(ch chan<- prometheus.Metric) {
-	snapAlloc, err := c.getSnapshotAllocatedSize()
+func (c *snapshotCollector) collect(ch chan<- prometheus.Metric) error {
+	pools, err := c.backend.collect(c.base.ctx, ch)
 	if err != nil {
-		c.base.logger.Errorf("Failed to get snapshot allocated size: %v", err)
-		return
+		c.base.logger.Errorf("Failed to get %s snapshots: %v", c.backendName, err)
+		return err
 	}
 
-	for _, mt := range metric.SnapshotStatusToPromMetrics(snapAlloc) {
-		ch <- mt
+	for _, pool := range pools {
+		pool.Backend = c.backendName
+		for _, mt := range pool.ToPromMetrics() {
+			ch <- mt
+		}
 	}
+
+	return nil
 }
 
-func (c *snapshotCollector) getSnapshotAllocatedSize() (float64, error) {
+// runCommand runs the command and returns its output. It is run through sudo
+// if asRoot is set and the exporter does not run as root.
+func runCommand(ctx context.Context, asRoot bool, name string, args ...string) ([]byte, error) {
+	program := name
+	if asRoot && os.Geteuid() != 0 {
+		args = append([]string{"-n", name}, args...)
+		name = "sudo"
+	}
+
 	var out bytes.Buffer
 	var stderr bytes.Buffer
 
-	snap := strings.ReplaceAll(c.snapDir, "/", "\\/")
-
-	cmd := exec.Command("bash", "-c", fmt.Sprintf("df | awk '/%s$/'", snap))
+	cmd := exec.CommandContext(ctx, name, args...)
 	cmd.Stdout = &out
 	cmd.Stderr = &stderr
 	if err := cmd.Run(); err != nil {
-		return 0, fmt.Errorf("failed to get snapshot allocated size: %v", stderr.String())
-	}
-
-	isBackupMounted := out.String()
-	snapInfo := ""
-
-	if string(isBackupMounted) != "" {
-		cmd = exec.Command("bash", "-c", "sudo lvs | awk '$6!~/[^0-9.]/&&$6>0{print$6}'")
-		out.Reset()
-		stderr.Reset()
-		cmd.Stdout = &out
-		cmd.Stderr = &stderr
-		if err := cmd.Run(); err != nil {
-			return 0, fmt.Errorf("failed to get snapshot allocated size: %v", stderr.String())
+		// The command is killed when ctx is done, e.g. at the scrape deadline.
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("failed to run %s: %w", program, ctx.Err())
 		}
-		snapInfo = strings.TrimSuffix(out.String(), "\n")
-	} else {
-		snapInfo = "0"
-	}
-
-	if snapInfo == "" {
-		snapInfo = "0"
-	}
-
-	snapshot, err := strconv.ParseFloat(snapInfo, 64)
-	if err != nil {
-		return 0, fmt.Errorf("failed to parse snapshot allocated size %s: %v", snapInfo, err)
+		return nil, fmt.Errorf("failed to run %s: %w: %s", program, err, strings.TrimSpace(stderr.String()))
 	}
 
-	return snapshot, nil
+	return out.Bytes(), nil
 }
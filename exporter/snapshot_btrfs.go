@@ -0,0 +1,209 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"mobserver/internal/metric"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// btrfsTimeLayout is the format of otime in btrfs subvolume list, in the local time zone.
+const btrfsTimeLayout = "2006-01-02 15:04:05"
+
+// btrfsBackend reads the snapshots of the btrfs filesystem of the backup directory, which is the only pool.
+// The space consumed by the snapshots is their exclusive size, which is known only if quotas are enabled.
+type btrfsBackend struct {
+	snapDir string
+	logger  *logrus.Logger
+}
+
+func (b *btrfsBackend) command() string {
+	return "btrfs"
+}
+
+func (b *btrfsBackend) collect(ctx context.Context, _ chan<- prometheus.Metric) ([]*metric.SnapshotPool, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(b.snapDir, &stat); err != nil {
+		return nil, fmt.Errorf("failed to get free space of %s: %w", b.snapDir, err)
+	}
+
+	pool := &metric.SnapshotPool{
+		Pool:      b.snapDir,
+		NewestAge: math.NaN(),
+		Free:      float64(stat.Bavail) * float64(stat.Bsize),
+	}
+
+	// The snapshots of the whole filesystem are listed, with their paths from its top level.
+	prefix, err := btrfsPath(b.snapDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get btrfs path of %s: %w", b.snapDir, err)
+	}
+
+	out, err := runCommand(ctx, true, "btrfs", "subvolume", "list", "-s", b.snapDir)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots, err := parseBtrfsSnapshots(out, prefix, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, s := range snapshots {
+		ids = append(ids, s.id)
+		pool.AddSnapshot(s.age)
+	}
+
+	pool.Used, err = b.exclusiveSize(ctx, ids)
+	if err != nil {
+		b.logger.Debugf("Cannot get the space consumed by the btrfs snapshots, quotas may be disabled: %v", err)
+		pool.Used = math.NaN()
+	}
+
+	return []*metric.SnapshotPool{pool}, nil
+}
+
+type btrfsSnapshot struct {
+	id   string
+	path string
+	// age is NaN if the creation time is unknown.
+	age float64
+}
+
+// parseBtrfsSnapshots parses the output of btrfs subvolume list -s, keeping the snapshots under prefix,
+// the path of the directory from the top level of the filesystem, or all of them if prefix is empty.
+func parseBtrfsSnapshots(out []byte, prefix string, now time.Time) ([]btrfsSnapshot, error) {
+	prefix = strings.Trim(prefix, "/")
+
+	snapshots := []btrfsSnapshot{}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// ID 257 gen 12 cgen 12 top level 5 otime 2024-01-01 10:00:00 path snapshots/daily
+		line := scanner.Text()
+		fields := strings.Fields(line)
+		if len(fields) < 2 || fields[0] != "ID" {
+			continue
+		}
+
+		// The path is the rest of the line, since it may have spaces.
+		i := strings.Index(line, " path ")
+		if i < 0 {
+			continue
+		}
+		path := strings.Trim(line[i+len(" path "):], "/")
+		if prefix != "" && path != prefix && !strings.HasPrefix(path, prefix+"/") {
+			continue
+		}
+
+		s := btrfsSnapshot{id: fields[1], path: path, age: math.NaN()}
+		for i := range fields {
+			if fields[i] == "otime" && i+2 < len(fields) {
+				if created, err := time.ParseInLocation(btrfsTimeLayout, fields[i+1]+" "+fields[i+2], time.Local); err == nil {
+					s.age = now.Sub(created).Seconds()
+				}
+				break
+			}
+		}
+		snapshots = append(snapshots, s)
+	}
+
+	return snapshots, scanner.Err()
+}
+
+// btrfsPath returns the path of dir from the top level of its btrfs filesystem, which is the root of
+// its mount in /proc/self/mountinfo, e.g. /@ for a subvolume mounted with subvol=@, followed by
+// the path of dir from the mount point.
+func btrfsPath(dir string) (string, error) {
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	root, mountPoint := "", ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 0:32 /@ /data rw,relatime shared:1 - btrfs /dev/sda2 rw,subvol=/@
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		point := unescapeMountPath(fields[4])
+		rel, err := filepath.Rel(point, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
+		}
+		// The deepest mount holds dir, and the last mount on a point hides the previous ones.
+		if len(point) >= len(mountPoint) {
+			root, mountPoint = unescapeMountPath(fields[3]), point
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if mountPoint == "" {
+		return "", fmt.Errorf("no mount of %s", dir)
+	}
+
+	rel, err := filepath.Rel(mountPoint, dir)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Trim(filepath.Join(root, rel), "/"), nil
+}
+
+// exclusiveSize returns the sum of the exclusive sizes of the qgroups of the subvolumes.
+func (b *btrfsBackend) exclusiveSize(ctx context.Context, ids []string) (float64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	// qgroupid  rfer   excl
+	// 0/257     16384  16384
+	out, err := runCommand(ctx, true, "btrfs", "qgroup", "show", "--raw", b.snapDir)
+	if err != nil {
+		return 0, err
+	}
+
+	excl := make(map[string]float64)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 || !strings.HasPrefix(fields[0], "0/") {
+			continue
+		}
+		v, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid exclusive size %q of qgroup %s", fields[2], fields[0])
+		}
+		excl[strings.TrimPrefix(fields[0], "0/")] = v
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	used := 0.0
+	for _, id := range ids {
+		used += excl[id]
+	}
+
+	return used, nil
+}
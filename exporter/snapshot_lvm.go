@@ -0,0 +1,217 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"mobserver/internal/metric"
+	"mobserver/internal/model"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// lvsFields is the columns of the lvs report read by the LVM backend.
+var lvsFields = []string{
+	"vg_name", "lv_name", "vg_free", "lv_size", "data_percent", "metadata_percent",
+	"origin", "lv_time", "lv_kernel_major", "lv_kernel_minor",
+}
+
+// lvTimeLayout is the format of lv_time in the lvs report.
+const lvTimeLayout = "2006-01-02 15:04:05 -0700"
+
+// lvmBackend reads the snapshots of LVM from the JSON report of lvs. The pools are the volume groups.
+type lvmBackend struct {
+	snapDir string
+	logger  *logrus.Logger
+}
+
+func (b *lvmBackend) command() string {
+	return "lvs"
+}
+
+// collect also sends the metrics of every logical volume, and the allocation of the snapshot
+// mounted on the backup directory.
+func (b *lvmBackend) collect(ctx context.Context, ch chan<- prometheus.Metric) ([]*metric.SnapshotPool, error) {
+	volumes, err := b.getLogicalVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pools := []*metric.SnapshotPool{}
+	byVG := make(map[string]*metric.SnapshotPool)
+
+	for i := range volumes {
+		doc := &volumes[i]
+
+		pool, ok := byVG[doc.VGName]
+		if !ok {
+			free, err := strconv.ParseFloat(doc.VGFree, 64)
+			if err != nil {
+				free = math.NaN()
+			}
+			pool = &metric.SnapshotPool{Pool: doc.VGName, NewestAge: math.NaN(), Free: free}
+			byVG[doc.VGName] = pool
+			pools = append(pools, pool)
+		}
+
+		lv, err := logicalVolumeStatus(doc, now)
+		if err != nil {
+			b.logger.Warnf("Cannot parse logical volume %s/%s: %v", doc.VGName, doc.LVName, err)
+			continue
+		}
+
+		for _, mt := range lv.ToPromMetrics() {
+			ch <- mt
+		}
+
+		if doc.Origin != "" {
+			pool.AddSnapshot(lv.SnapshotAge)
+			if !math.IsNaN(lv.DataPercent) {
+				pool.Used += lv.Size * lv.DataPercent / 100
+			}
+		}
+	}
+
+	// snapshot_allocation is the data usage of the volume mounted on the backup directory,
+	// 0 if it is not mounted on a logical volume.
+	snapAlloc := 0.0
+	if device, err := mountedDevice(b.snapDir); err != nil {
+		b.logger.Warnf("Cannot find the device of %s: %v", b.snapDir, err)
+	} else {
+		for i := range volumes {
+			if volumes[i].KernelMajor+":"+volumes[i].KernelMinor == device {
+				snapAlloc, _ = strconv.ParseFloat(volumes[i].DataPercent, 64)
+				break
+			}
+		}
+	}
+
+	for _, mt := range metric.SnapshotStatusToPromMetrics(snapAlloc) {
+		ch <- mt
+	}
+
+	return pools, nil
+}
+
+// getLogicalVolumes runs lvs, through sudo unless the exporter runs as root.
+func (b *lvmBackend) getLogicalVolumes(ctx context.Context) ([]model.LogicalVolumeDoc, error) {
+	out, err := runCommand(ctx, true, "lvs", "--reportformat", "json", "--units", "b", "--nosuffix",
+		"-o", strings.Join(lvsFields, ","))
+	if err != nil {
+		return nil, err
+	}
+
+	var report model.LVSReportDoc
+	if err := json.Unmarshal(out, &report); err != nil {
+		return nil, fmt.Errorf("failed to parse lvs report: %w", err)
+	}
+
+	volumes := []model.LogicalVolumeDoc{}
+	for _, r := range report.Report {
+		volumes = append(volumes, r.LV...)
+	}
+
+	return volumes, nil
+}
+
+func logicalVolumeStatus(doc *model.LogicalVolumeDoc, now time.Time) (*metric.LogicalVolume, error) {
+	lv := &metric.LogicalVolume{
+		VG:          doc.VGName,
+		LV:          doc.LVName,
+		Origin:      doc.Origin,
+		SnapshotAge: math.NaN(),
+	}
+
+	var err error
+	if lv.Size, err = strconv.ParseFloat(doc.Size, 64); err != nil {
+		return nil, fmt.Errorf("invalid size %q", doc.Size)
+	}
+	if lv.DataPercent, err = parsePercent(doc.DataPercent); err != nil {
+		return nil, err
+	}
+	if lv.MetadataPercent, err = parsePercent(doc.MetadataPercent); err != nil {
+		return nil, err
+	}
+
+	if doc.Origin != "" {
+		created, err := time.Parse(lvTimeLayout, doc.Time)
+		if err != nil {
+			return nil, fmt.Errorf("invalid creation time %q", doc.Time)
+		}
+		lv.SnapshotAge = now.Sub(created).Seconds()
+	}
+
+	return lv, nil
+}
+
+// parsePercent returns NaN for the percentages which are empty because they do not apply to the volume.
+func parsePercent(s string) (float64, error) {
+	if s == "" {
+		return math.NaN(), nil
+	}
+
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", s)
+	}
+
+	return v, nil
+}
+
+// mountedDevice returns the major:minor of the device mounted exactly on dir from /proc/self/mountinfo,
+// or an empty string if dir is not a mount point.
+func mountedDevice(dir string) (string, error) {
+	dir = filepath.Clean(dir)
+
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close() //nolint:errcheck
+
+	device := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		// The last mount on dir hides the previous ones.
+		if unescapeMountPath(fields[4]) == dir {
+			device = fields[2]
+		}
+	}
+
+	return device, scanner.Err()
+}
+
+// unescapeMountPath decodes the octal escapes of the spaces, tabs, newlines and backslashes of mountinfo.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseBtrfsSnapshots(t *testing.T) {
+	out := []byte(`ID 257 gen 12 cgen 12 top level 5 otime 2024-01-01 10:00:00 path @/backup/snaps/daily
+ID 258 gen 13 cgen 13 top level 5 otime 2024-01-01 11:00:00 path @/backup/snaps/hourly 1
+ID 259 gen 14 cgen 14 top level 5 otime 2024-01-01 11:30:00 path @/other/snap
+ID 260 gen 15 cgen 15 top level 5 otime 2024-01-01 11:45:00 path @/backup/snapshots-old
+ID 261 gen 16 cgen 16 top level 5 otime - path @/backup/snaps/unknown
+`)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name   string
+		prefix string
+		want   []string
+	}{
+		{name: "directory", prefix: "@/backup/snaps", want: []string{"257", "258", "261"}},
+		{name: "directory with slashes", prefix: "/@/backup/snaps/", want: []string{"257", "258", "261"}},
+		{name: "top level", prefix: "", want: []string{"257", "258", "259", "260", "261"}},
+		{name: "no snapshot", prefix: "@/none", want: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshots, err := parseBtrfsSnapshots(out, tt.prefix, now)
+			if err != nil {
+				t.Fatalf("parseBtrfsSnapshots() error = %v", err)
+			}
+
+			ids := []string{}
+			for _, s := range snapshots {
+				ids = append(ids, s.id)
+			}
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("parseBtrfsSnapshots(%q) = %v, want %v", tt.prefix, ids, tt.want)
+			}
+		})
+	}
+
+	snapshots, _ := parseBtrfsSnapshots(out, "@/backup/snaps", now)
+	if got := snapshots[1]; got.path != "@/backup/snaps/hourly 1" || got.age != 3600 {
+		t.Errorf("snapshot = %+v, want path %q and age 3600", got, "@/backup/snaps/hourly 1")
+	}
+	if got := snapshots[2]; !math.IsNaN(got.age) {
+		t.Errorf("age of the snapshot without otime = %v, want NaN", got.age)
+	}
+}
+
+func TestRunCommandErrors(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := runCommand(ctx, false, "sleep", "5")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("runCommand() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if class := errorClass(err); class != "timeout" {
+		t.Errorf("errorClass(%v) = %q, want %q", err, class, "timeout")
+	}
+
+	_, err = runCommand(context.Background(), false, "false")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		t.Errorf("runCommand() error = %v, want an *exec.ExitError", err)
+	}
+}
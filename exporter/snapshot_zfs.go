@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"mobserver/internal/metric"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// zfsBackend reads the snapshots of ZFS from zfs list. The pools are the root datasets.
+type zfsBackend struct{}
+
+func (b *zfsBackend) command() string {
+	return "zfs"
+}
+
+func (b *zfsBackend) collect(ctx context.Context, _ chan<- prometheus.Metric) ([]*metric.SnapshotPool, error) {
+	// The available space of the root dataset is the free space of the pool usable by the datasets.
+	out, err := runCommand(ctx, false, "zfs", "list", "-H", "-p", "-d", "0", "-o", "name,avail")
+	if err != nil {
+		return nil, err
+	}
+
+	pools := []*metric.SnapshotPool{}
+	byName := make(map[string]*metric.SnapshotPool)
+
+	err = scanZFSList(out, 2, func(fields []string) error {
+		free, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid available space %q of %s", fields[1], fields[0])
+		}
+		pool := &metric.SnapshotPool{Pool: fields[0], NewestAge: math.NaN(), Free: free}
+		byName[pool.Pool] = pool
+		pools = append(pools, pool)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = runCommand(ctx, false, "zfs", "list", "-H", "-p", "-t", "snapshot", "-o", "name,creation,used")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = scanZFSList(out, 3, func(fields []string) error {
+		// pool/dataset@snapshot
+		pool, ok := byName[fields[0][:strings.IndexAny(fields[0]+"@", "/@")]]
+		if !ok {
+			return nil
+		}
+
+		creation, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid creation %q of %s", fields[1], fields[0])
+		}
+		used, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return fmt.Errorf("invalid used space %q of %s", fields[2], fields[0])
+		}
+
+		pool.AddSnapshot(now.Sub(time.Unix(creation, 0)).Seconds())
+		pool.Used += used
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pools, nil
+}
+
+// scanZFSList calls fn with the columns of every line of the scripted output of zfs list,
+// which has n tab separated columns.
+func scanZFSList(out []byte, n int, fn func(fields []string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		if scanner.Text() == "" {
+			continue
+		}
+
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != n {
+			return fmt.Errorf("unexpected line of zfs list: %q", scanner.Text())
+		}
+		if err := fn(fields); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
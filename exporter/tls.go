@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"crypto/x509"
+	"mobserver/internal/mongoutils"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
+)
+
+// tlsOpts returns the TLS settings of the connection, or nil to use the ones of URI.
+func tlsOpts(opts *Opts) *mongoutils.TLSOpts {
+	if !opts.TLS && opts.TLSCAFile == "" && opts.TLSCertFile == "" && !opts.TLSAllowInvalidHostnames {
+		return nil
+	}
+
+	return &mongoutils.TLSOpts{
+		CAFile:                opts.TLSCAFile,
+		CertFile:              opts.TLSCertFile,
+		KeyFile:               opts.TLSKeyFile,
+		KeyPassword:           opts.TLSKeyPassword,
+		AllowInvalidHostnames: opts.TLSAllowInvalidHostnames,
+	}
+}
+
+// validateTLSOpts checks the authentication mechanism and that the TLS files can be loaded,
+// so that a wrong path or password fails on startup instead of on every scrape.
+func validateTLSOpts(opts *Opts) error {
+	switch opts.AuthMechanism {
+	case "", "SCRAM-SHA-256", "MONGODB-X509", "PLAIN":
+	default:
+		return &ValidationError{Option: "AuthMechanism", Value: opts.AuthMechanism, Err: ErrUnknownAuthMechanism}
+	}
+
+	if opts.AuthMechanism == "MONGODB-X509" && opts.TLSCertFile == "" {
+		cs, _ := connstring.Parse(opts.URI)
+		if cs.SSLClientCertificateKeyFile == "" {
+			return &ValidationError{Option: "TLSCertFile", Err: ErrClientCertRequired}
+		}
+	}
+
+	if opts.TLSCAFile != "" {
+		if _, err := mongoutils.ReadCertificates(opts.TLSCAFile); err != nil {
+			return &ValidationError{Option: "TLSCAFile", Value: opts.TLSCAFile, Err: err}
+		}
+	}
+
+	if opts.TLSCertFile != "" {
+		client := &mongoutils.TLSOpts{CertFile: opts.TLSCertFile, KeyFile: opts.TLSKeyFile, KeyPassword: opts.TLSKeyPassword}
+		if _, err := client.Config(); err != nil {
+			return &ValidationError{Option: "TLSCertFile", Value: opts.TLSCertFile, Err: err}
+		}
+	}
+
+	return nil
+}
+
+// certMetrics exports the expiry of the certificates of the TLS options. The files are read on every
+// collection, so that the metrics follow the rotation of the certificates.
+type certMetrics struct {
+	opts   *Opts
+	logger *logrus.Logger
+	expiry *prometheus.Desc
+}
+
+func newCertMetrics(opts *Opts) *certMetrics {
+	return &certMetrics{
+		opts:   opts,
+		logger: opts.Logger,
+		expiry: prometheus.NewDesc("mobserver_tls_certificate_expiry_timestamp_seconds",
+			"Expiry of the certificates of the MongoDB connection by the type (ca, client) in seconds since the epoch",
+			[]string{"type", "subject", "serial"}, nil),
+	}
+}
+
+func (m *certMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- m.expiry
+}
+
+func (m *certMetrics) Collect(ch chan<- prometheus.Metric) {
+	for certType, path := range map[string]string{"ca": m.opts.TLSCAFile, "client": m.opts.TLSCertFile} {
+		if path == "" {
+			continue
+		}
+
+		certs, err := mongoutils.ReadCertificates(path)
+		if err != nil {
+			m.logger.Errorf("Cannot read %s certificates: %v", certType, err)
+			continue
+		}
+
+		if certType == "client" {
+			// The others are the chain of the client certificate.
+			certs = certs[:1]
+		}
+
+		for _, cert := range certs {
+			ch <- m.metric(certType, cert)
+		}
+	}
+}
+
+func (m *certMetrics) metric(certType string, cert *x509.Certificate) prometheus.Metric {
+	return prometheus.MustNewConstMetric(m.expiry, prometheus.GaugeValue, float64(cert.NotAfter.Unix()),
+		certType, cert.Subject.String(), cert.SerialNumber.String())
+}
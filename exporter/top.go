@@ -4,22 +4,25 @@ import (
 	"context"
 	"mobserver/internal/metric"
 	"mobserver/internal/model"
+	"mobserver/internal/mongoutils"
+	"strings"
 
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/sirupsen/logrus"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/mongo"
 )
 
 type topCollector struct {
 	ctx  context.Context
 	base *baseCollector
+
+	nsFilter *metric.NamespaceFilter
 }
 
-func newTopCollector(client *mongo.Client, logger *logrus.Logger) prometheus.Collector {
+func newTopCollector(base *baseCollector, nsFilter *metric.NamespaceFilter) prometheus.Collector {
 	return &topCollector{
-		ctx:  context.Background(),
-		base: newBaseCollector(client, logger),
+		ctx:      base.ctx,
+		base:     base,
+		nsFilter: nsFilter,
 	}
 }
 
@@ -31,14 +34,14 @@ func (c *topCollector) Collect(ch chan<- prometheus.Metric) {
 	c.base.Collect(ch)
 }
 
-func (c *topCollector) collect(ch chan<- prometheus.Metric) {
+func (c *topCollector) collect(ch chan<- prometheus.Metric) error {
 	var result struct {
 		Totals bson.M `bson:"totals"`
 	}
 
-	if err := c.base.client.Database("admin").RunCommand(c.ctx, bson.D{{Key: "top", Value: 1}}).Decode(&result); err != nil {
+	if err := c.base.client.Database("admin").RunCommand(c.ctx, mongoutils.WithMaxTime(c.ctx, bson.D{{Key: "top", Value: 1}})).Decode(&result); err != nil {
 		c.base.logger.Errorf("Failed to get top command: %v", err)
-		return
+		return err
 	}
 
 	delete(result.Totals, "note")
@@ -48,19 +51,82 @@ func (c *topCollector) collect(ch chan<- prometheus.Metric) {
 
 	if err := bson.Unmarshal(tmp, &tops); err != nil {
 		c.base.logger.Errorf("Failed to parse top command: %v", err)
-		return
+		return err
 	}
 
+	// Rank the namespaces by the time spent on them.
+	activity := make(map[string]float64, len(tops))
 	for ns, top := range tops {
-		if metric.IsSystemCollection(ns) {
+		if c.nsFilter.Match(ns) {
+			activity[ns] = float64(top["total"].Time)
+		}
+	}
+	selected := c.base.limitNamespaces(activity, true)
+
+	counters := make(map[string]map[string]float64, len(activity))
+	dropped := 0
+
+	for ns := range activity {
+		counters[ns] = topCounters(tops[ns])
+		if !selected[ns] {
+			dropped++
 			continue
 		}
 
 		db, coll := metric.ParseNamespace(ns)
 		metricLabels := []string{db, coll}
 
-		for _, mt := range metric.NewTop(top).ToPromMetrics(metricLabels...) {
+		for _, mt := range metric.NewTop(tops[ns]).ToPromMetrics(metricLabels...) {
+			ch <- mt
+		}
+	}
+
+	// The increases of the aggregated namespaces are accumulated, so __other__ stays a counter
+	// when the selected namespaces change.
+	other := c.base.nsLimiter.AggregateCounters(counters, selected)
+	if dropped > 0 || len(other) > 0 {
+		db, coll := metric.ParseNamespace(metric.OtherNamespace)
+		for _, mt := range metric.NewTop(topFields(other)).ToPromMetrics(db, coll) {
 			ch <- mt
 		}
 	}
+
+	for _, mt := range c.base.namespacesDropped(dropped) {
+		ch <- mt
+	}
+
+	return nil
+}
+
+// topCounters flattens the fields of top of a namespace into counters named <field>.time and <field>.count.
+func topCounters(fields map[string]model.TopField) map[string]float64 {
+	counters := make(map[string]float64, 2*len(fields))
+	for name, field := range fields {
+		counters[name+".time"] = float64(field.Time)
+		counters[name+".count"] = float64(field.Count)
+	}
+
+	return counters
+}
+
+// topFields is the reverse of topCounters.
+func topFields(counters map[string]float64) map[string]model.TopField {
+	fields := make(map[string]model.TopField)
+	for name, v := range counters {
+		field, kind := name, ""
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			field, kind = name[:i], name[i+1:]
+		}
+
+		f := fields[field]
+		switch kind {
+		case "time":
+			f.Time = int64(v)
+		case "count":
+			f.Count = int64(v)
+		}
+		fields[field] = f
+	}
+
+	return fields
 }
@@ -0,0 +1,100 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const defaultRedetectInterval = 5 * time.Minute
+
+// detectTopology detects the server and returns a copy of opts without the collectors which do not
+// apply to it, with the reasons by the names of the disabled collectors.
+func detectTopology(ctx context.Context, client *mongo.Client, opts *Opts) (*Opts, map[string]string, error) {
+	topology := opts.clone()
+	detectServer(ctx, client, topology)
+
+	reasons, err := validateToplogyOpts(ctx, client, topology)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to validate topology options: %w", err)
+	}
+
+	return topology, reasons, nil
+}
+
+// detect returns the options for the detected server. The server is detected on the first call,
+// after RedetectInterval and after a collection failed for the connection or the role of the server
+// (see observeResult), so that the collectors follow the changes
+// of its role, e.g. a member reconfigured into an arbiter or a replica set initiated after startup.
+func (e *Exporter) detect(ctx context.Context, client *mongo.Client) (*Opts, error) {
+	e.detectMu.Lock()
+	defer e.detectMu.Unlock()
+
+	e.lock.Lock()
+	opts := e.opts
+	prev := e.topology
+	detectedAt := e.detectedAt
+	e.lock.Unlock()
+
+	if prev != nil && time.Since(detectedAt) < redetectInterval(opts) {
+		return prev, nil
+	}
+
+	topology, reasons, err := detectTopology(ctx, client, opts)
+	if err != nil {
+		if prev == nil {
+			return nil, err
+		}
+
+		// Keep collecting with the last detected server, and detect it again on the next collection.
+		e.logger.Errorf("Cannot detect MongoDB server again: %v", err)
+		return prev, nil
+	}
+
+	logTopologyChanges(e.logger, prev, topology, reasons)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	// Drop the result if the options were replaced by a reload in the meantime.
+	if e.opts == opts {
+		e.topology = topology
+		e.detectedAt = time.Now()
+	}
+
+	return topology, nil
+}
+
+// invalidateTopology makes the next collection detect the server again.
+func (e *Exporter) invalidateTopology() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.detectedAt = time.Time{}
+}
+
+func redetectInterval(opts *Opts) time.Duration {
+	if opts.RedetectInterval > 0 {
+		return opts.RedetectInterval
+	}
+
+	return defaultRedetectInterval
+}
+
+// logTopologyChanges logs the collectors disabled or enabled again since the previous detection.
+// prev is nil for the first detection.
+func logTopologyChanges(logger *logrus.Logger, prev, topology *Opts, reasons map[string]string) {
+	for _, spec := range registeredCollectors() {
+		name := spec.name
+
+		switch {
+		case reasons[name] != "" && (prev == nil || prev.enabled[name]):
+			logger.Warnf("Disabling %s collector because %s", name, reasons[name])
+		case topology.enabled[name] && prev != nil && !prev.enabled[name]:
+			logger.Warnf("Enabling %s collector because the role of the server changed", name)
+		}
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"mobserver/internal/metric"
 	"mobserver/internal/mongoutils"
-	"os"
 	"os/exec"
 	"strings"
 
@@ -13,129 +13,163 @@ import (
 	"go.mongodb.org/mongo-driver/x/mongo/driver/connstring"
 )
 
-func validateOpts(ctx context.Context, client *mongo.Client, opts *Opts) error {
+var (
+	// ErrUnknownCollector is returned when a collector is not registered.
+	ErrUnknownCollector = errors.New("unknown collector")
+	// ErrBackupDirRequired is returned when the backupstats collector, or the lvmsnapshotstats collector
+	// for the LVM or btrfs backend, has no backup directory.
+	ErrBackupDirRequired = errors.New("backup directory is required for localhost")
+	// ErrUnknownAuthMechanism is returned when the authentication mechanism is not supported.
+	ErrUnknownAuthMechanism = errors.New("unknown authentication mechanism, must be one of SCRAM-SHA-256, MONGODB-X509, PLAIN")
+	// ErrClientCertRequired is returned when MONGODB-X509 is used without a client certificate.
+	ErrClientCertRequired = errors.New("client certificate is required for MONGODB-X509")
+	// ErrNegativeLimit is returned when a namespace limit is negative.
+	ErrNegativeLimit = errors.New("limit must not be negative")
+	// ErrUnknownSnapshotBackend is returned when the snapshot backend is not one of lvm, zfs and btrfs.
+	ErrUnknownSnapshotBackend = errors.New("unknown snapshot backend, must be one of lvm, zfs, btrfs")
+)
+
+// ValidationError is returned for an invalid option. Err is one of the errors above,
+// or the error of checking the option.
+type ValidationError struct {
+	// Option is the name of the field of Opts.
+	Option string
+	Value  string
+	Err    error
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("invalid %s: %v", e.Option, e.Err)
+	}
+
+	return fmt.Sprintf("invalid %s %q: %v", e.Option, e.Value, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// validateOpts validates the options which do not depend on the server,
+// and enables the collectors. The topology is validated on detection.
+func validateOpts(opts *Opts) error {
+	if err := resolveCollectors(opts); err != nil {
+		return err
+	}
+
 	if err := validateGeneralOpts(opts); err != nil {
-		return fmt.Errorf("failed to validate general options: %w", err)
+		return err
 	}
 
 	if err := validateLocalhostOpts(opts); err != nil {
-		return fmt.Errorf("failed to validate localhost options: %w", err)
+		return err
 	}
 
-	if err := validateToplogyOpts(ctx, client, opts); err != nil {
-		return fmt.Errorf("failed to validate topology options: %w", err)
+	if err := validateSnapshotOpts(opts); err != nil {
+		return err
 	}
 
-	if err := validateSnapshotStats(opts); err != nil {
-		return fmt.Errorf("failed to validate snapshot status options: %w", err)
+	if err := validateLabelOpts(opts); err != nil {
+		return err
 	}
 
-	return nil
-}
-
-func validateGeneralOpts(opts *Opts) error {
-	if !opts.EnableLVMSnapshotStats && !opts.EnableRollbackStats {
-		return nil
+	if err := compileNamespaceFilter(opts); err != nil {
+		return err
 	}
 
-	checkCommands := []string{
-		"ls",
-		"awk",
-		"grep",
+	if err := validateLimitOpts(opts); err != nil {
+		return err
 	}
 
-	for _, cmd := range checkCommands {
-		if _, err := exec.LookPath(cmd); err != nil {
-			if errors.Is(err, exec.ErrNotFound) {
-				opts.Logger.Warnf("Cannot find %s in PATH. Disabling rollback, snapshot status metrics", cmd)
-				opts.EnableRollbackStats = false
-				opts.EnableLVMSnapshotStats = false
-			} else {
-				return fmt.Errorf("failed to check for %s: %w", cmd, err)
-			}
-		}
+	if err := validateTLSOpts(opts); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-func validateSnapshotStats(opts *Opts) error {
-	if !opts.EnableLVMSnapshotStats {
-		return nil
+// compileNamespaceFilter compiles the namespace patterns of the options.
+func compileNamespaceFilter(opts *Opts) error {
+	patterns := metric.NamespacePatterns{
+		IncludeDatabases:   opts.IncludeDatabases,
+		ExcludeDatabases:   opts.ExcludeDatabases,
+		IncludeCollections: opts.IncludeCollections,
+		ExcludeCollections: opts.ExcludeCollections,
+	}
+	if patterns.ExcludeDatabases == nil {
+		patterns.ExcludeDatabases = metric.DefaultExcludeDatabases
 	}
 
-	checkCommands := []string{
-		"df",
-		"lvs",
+	filter, err := metric.NewNamespaceFilter(patterns)
+	if err != nil {
+		return &ValidationError{Option: "Namespaces", Err: err}
 	}
+	opts.nsFilter = filter
+	opts.systemNsFilter = filter
 
-	for _, cmd := range checkCommands {
-		if _, err := exec.LookPath(cmd); err != nil {
-			if errors.Is(err, exec.ErrNotFound) {
-				opts.Logger.Warnf("Cannot find %s in PATH. Disabling snapshot status metrics", cmd)
-				opts.EnableLVMSnapshotStats = false
-			} else {
-				return fmt.Errorf("failed to check for %s: %w", cmd, err)
-			}
+	if patterns.ExcludeCollections == nil {
+		patterns.ExcludeCollections = metric.DefaultExcludeCollections
+		if opts.systemNsFilter, err = metric.NewNamespaceFilter(patterns); err != nil {
+			return &ValidationError{Option: "Namespaces", Err: err}
 		}
 	}
 
 	return nil
 }
 
-func validateToplogyOpts(ctx context.Context, client *mongo.Client, opts *Opts) error {
-	hello, err := mongoutils.GetHello(ctx, client)
-	if err != nil {
-		return fmt.Errorf("failed to get hello result: %w", err)
+// validateGeneralOpts disables the collectors whose commands are not found in PATH.
+func validateGeneralOpts(opts *Opts) error {
+	for _, spec := range enabledCollectors(opts, nil) {
+		for _, cmd := range spec.requiredCommands {
+			if _, err := exec.LookPath(cmd); err != nil {
+				if !errors.Is(err, exec.ErrNotFound) {
+					return &ValidationError{Option: "Collectors", Value: spec.name, Err: fmt.Errorf("failed to check for %s: %w", cmd, err)}
+				}
+				disableCollector(opts, spec.name, fmt.Sprintf("%s is not found in PATH", cmd))
+				break
+			}
+		}
 	}
 
-	if hello.ArbiterOnly {
-		opts.Logger.Warnf("This is an arbiter, disabling all metrics except for replset status")
-		opts.EnableTopMetrics = false
-		opts.EnableCurrentopMetrics = false
-		opts.EnableOplogStats = false
-		opts.EnableShardingStats = false
-		opts.EnableRollbackStats = false
-		opts.EnableLVMSnapshotStats = false
-	}
+	return nil
+}
 
-	if hello.Msg == "isdbgrid" {
-		// mongos
-		if opts.EnableLVMSnapshotStats {
-			opts.Logger.Warnf("Disabling snapshot stats because this is a mongos")
-			opts.EnableLVMSnapshotStats = false
-		}
-		if opts.EnableRollbackStats {
-			opts.Logger.Warnf("Disabling rollback stats because this is a mongos")
-			opts.EnableRollbackStats = false
-		}
-		if opts.EnableOplogStats {
-			opts.Logger.Warnf("Disabling oplog stats because this is a mongos")
-			opts.EnableOplogStats = false
-		}
-		if opts.EnableCurrentopMetrics {
-			opts.Logger.Warnf("Disabling currentop metrics because this is a mongos")
-			opts.EnableCurrentopMetrics = false
-		}
-		if opts.EnableTopMetrics {
-			opts.Logger.Warnf("Disabling top stats because this is a mongos")
-			opts.EnableTopMetrics = false
-		}
+// validateToplogyOpts disables the collectors which do not apply to the server without logging,
+// since it runs again and again, and returns the reasons by the names of the collectors.
+func validateToplogyOpts(ctx context.Context, client *mongo.Client, opts *Opts) (map[string]string, error) {
+	hello, err := mongoutils.GetHello(ctx, client)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hello result: %w", err)
 	}
 
 	cmdLineOpts, err := mongoutils.GetCmdLineOpts(ctx, client)
 	if err != nil {
-		return fmt.Errorf("failed to get command line options: %w", err)
+		return nil, fmt.Errorf("failed to get command line options: %w", err)
 	}
 
-	if cmdLineOpts.Parsed.Sharding.ClusterRole != "configsvr" {
-		if opts.EnableShardingStats {
-			opts.Logger.Warnf("Disabling sharding stats because this is not a config server")
-			opts.EnableShardingStats = false
+	reasons := make(map[string]string)
+
+	for _, spec := range enabledCollectors(opts, nil) {
+		var reason string
+
+		switch {
+		case spec.notOnArbiter && hello.ArbiterOnly:
+			reason = "this is an arbiter"
+		case spec.notOnMongos && hello.Msg == "isdbgrid":
+			reason = "this is a mongos"
+		case spec.onlyOnConfigServer && cmdLineOpts.Parsed.Sharding.ClusterRole != "configsvr":
+			reason = "this is not a config server"
+		case spec.onlyOnReplicaSet && hello.SetName == "":
+			reason = "this is not a replica set member"
+		default:
+			continue
 		}
+
+		delete(opts.enabled, spec.name)
+		reasons[spec.name] = reason
 	}
 
-	return nil
+	return reasons, nil
 }
 
 func validateLocalhostOpts(opts *Opts) error {
@@ -144,27 +178,25 @@ func validateLocalhostOpts(opts *Opts) error {
 
 	for _, host := range cs.Hosts {
 		if !(strings.HasPrefix(host, "localhost") || strings.HasPrefix(host, "127.0.0.1")) {
-			opts.Logger.Errorf("Remote host %s detected in %s", host, opts.URI)
+			opts.Logger.Errorf("Remote host %s detected in %s", host, mongoutils.RedactURI(opts.URI))
 			isLocalhost = false
 			break
 		}
 	}
 
 	if !isLocalhost {
-		if opts.EnableLVMSnapshotStats {
-			opts.Logger.Warn("LVMSnapshotStats is not supported for remote MongoDB, disabling it")
-			opts.EnableLVMSnapshotStats = false
+		for _, spec := range enabledCollectors(opts, nil) {
+			switch {
+			case spec.name == "rollbackstats":
+				disableCollector(opts, spec.name, "it is not supported for remote MongoDB, replevents counts the rollbacks instead")
+			case spec.onlyOnLocalhost:
+				disableCollector(opts, spec.name, "it is not supported for remote MongoDB")
+			}
 		}
+	}
 
-		if opts.EnableRollbackStats {
-			opts.Logger.Warn("RollbackStats is not supported for remote MongoDB, disabling it")
-			opts.EnableRollbackStats = false
-		}
-	} else {
-		if opts.LVMSnapshotBackupDir == "" {
-			opts.Logger.Errorf("LVMSnapshotBackupDir should be set for localhost")
-			os.Exit(1)
-		}
+	if opts.enabled["backupstats"] && opts.LVMSnapshotBackupDir == "" {
+		return &ValidationError{Option: "LVMSnapshotBackupDir", Err: ErrBackupDirRequired}
 	}
 
 	return nil
@@ -5,15 +5,17 @@ go 1.16
 require (
 	github.com/alecthomas/kong v0.8.1
 	github.com/prometheus/client_golang v1.17.0
+	github.com/prometheus/client_model v0.4.1-0.20230718164431-9a2bf3000d16
 	github.com/prometheus/common v0.45.0
 	github.com/prometheus/exporter-toolkit v0.11.0
 	github.com/sirupsen/logrus v1.9.3
+	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a
 	go.mongodb.org/mongo-driver v1.12.1
+	gopkg.in/yaml.v2 v2.4.0
 )
 
 require (
 	github.com/google/uuid v1.3.0
 	github.com/klauspost/compress v1.16.7 // indirect
 	github.com/stretchr/testify v1.8.4 // indirect
-	github.com/youmark/pkcs8 v0.0.0-20201027041543-1326539a0a0a // indirect
 )
@@ -0,0 +1,126 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the content of the configuration file given by --config.file.
+// The values set in the file take precedence over the command line flags.
+type Config struct {
+	MongoDB    MongoDB              `yaml:"mongodb"`
+	Collection Collection           `yaml:"collection"`
+	Collectors map[string]Collector `yaml:"collectors"`
+	Namespaces Namespaces           `yaml:"namespaces"`
+	Labels     Labels               `yaml:"labels"`
+	Web        Web                  `yaml:"web"`
+	Log        Log                  `yaml:"log"`
+}
+
+type MongoDB struct {
+	URI              string `yaml:"uri"`
+	User             string `yaml:"user"`
+	Password         string `yaml:"password"`
+	PasswordFile     string `yaml:"password_file"`
+	URIFile          string `yaml:"uri_file"`
+	DirectConnect    *bool  `yaml:"direct_connect"`
+	GlobalConnPool   *bool  `yaml:"global_conn_pool"`
+	ConnectTimeoutMS int    `yaml:"connect_timeout_ms"`
+	TLS              TLS    `yaml:"tls"`
+	AuthMechanism    string `yaml:"auth_mechanism"`
+
+	PoolCheckInterval time.Duration `yaml:"pool_check_interval"`
+	PoolMaxFailures   int           `yaml:"pool_max_failures"`
+	RedetectInterval  time.Duration `yaml:"redetect_interval"`
+}
+
+// TLS is the TLS settings of the connection to MongoDB.
+type TLS struct {
+	Enabled               *bool  `yaml:"enabled"`
+	CAFile                string `yaml:"ca_file"`
+	CertFile              string `yaml:"cert_file"`
+	KeyFile               string `yaml:"key_file"`
+	KeyPassword           string `yaml:"key_password"`
+	AllowInvalidHostnames *bool  `yaml:"allow_invalid_hostnames"`
+}
+
+// Collection is the settings shared by all collectors.
+type Collection struct {
+	CollectAll *bool         `yaml:"collect_all"`
+	Background *bool         `yaml:"background"`
+	Interval   time.Duration `yaml:"interval"`
+}
+
+// Collector is the settings of a collector. Options that do not apply to the collector are rejected.
+type Collector struct {
+	Enabled  *bool         `yaml:"enabled"`
+	Interval time.Duration `yaml:"interval"`
+
+	// MaxNamespaces overrides the namespace limit for the per-namespace collectors.
+	MaxNamespaces *int `yaml:"max_namespaces"`
+
+	// currentopmetrics
+	SlowOpThresholdMS int `yaml:"slow_op_threshold_ms"`
+
+	// lvmsnapshotstats and backupstats
+	BackupDir string `yaml:"backup_dir"`
+	// lvmsnapshotstats
+	Backend string `yaml:"backend"`
+	// backupstats
+	Marker string `yaml:"marker"`
+}
+
+// Namespaces is the regular expressions selecting the namespaces of the per-namespace collectors.
+// A list set in the file replaces the one of the flags, and an empty list clears it.
+type Namespaces struct {
+	IncludeDatabases   []string `yaml:"include_databases"`
+	ExcludeDatabases   []string `yaml:"exclude_databases"`
+	IncludeCollections []string `yaml:"include_collections"`
+	ExcludeCollections []string `yaml:"exclude_collections"`
+
+	// Max is the number of namespaces exported separately by each per-namespace collector.
+	Max *int `yaml:"max"`
+}
+
+// Labels is the labels added to every metric of the collectors.
+type Labels struct {
+	// Identity is the names of the labels discovered from the server. An empty list disables them.
+	Identity []string          `yaml:"identity"`
+	Const    map[string]string `yaml:"const"`
+}
+
+// Web is the settings of the web server. Except for the timeout offset and the probe targets,
+// they are applied only on startup, since the listener is kept on reload.
+type Web struct {
+	ListenAddress string `yaml:"listen_address"`
+	TelemetryPath string `yaml:"telemetry_path"`
+	ProbePath     string `yaml:"probe_path"`
+	Config        string `yaml:"config"`
+	TimeoutOffset int    `yaml:"timeout_offset"`
+
+	// ProbeAllowedTargets is the targets the probe path may scrape. A list set in the file replaces the one of the flags.
+	ProbeAllowedTargets []string      `yaml:"probe_allowed_targets"`
+	ProbeTargetTTL      time.Duration `yaml:"probe_target_ttl"`
+}
+
+type Log struct {
+	Level string `yaml:"level"`
+}
+
+// Load reads and parses the configuration file.
+func Load(path string) (*Config, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read config file %s: %w", path, err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(content, cfg); err != nil {
+		return nil, fmt.Errorf("cannot parse config file %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,28 @@
+package metric
+
+import "github.com/prometheus/client_golang/prometheus"
+
+func CollectorStalenessToPromMetrics(staleness map[string]float64) []prometheus.Metric {
+	res := []prometheus.Metric{}
+	for name, v := range staleness {
+		raw := map[string]float64{"staleness_seconds": v}
+		res = append(res, buildPromMetrics(collectorMetricPrefix, raw, name)...)
+	}
+	return res
+}
+
+func CollectorResultToPromMetrics(name string, durationSecs float64, success bool) []prometheus.Metric {
+	raw := map[string]float64{
+		"duration_seconds": durationSecs,
+		"success":          0,
+	}
+	if success {
+		raw["success"] = 1
+	}
+	return buildPromMetrics(collectorMetricPrefix, raw, name)
+}
+
+func NamespacesDroppedToPromMetrics(name string, dropped int) []prometheus.Metric {
+	raw := map[string]float64{"namespaces_dropped": float64(dropped)}
+	return buildPromMetrics(collectorMetricPrefix, raw, name)
+}
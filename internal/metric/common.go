@@ -49,7 +49,3 @@ func ParseNamespace(ns string) (string, string) {
 
 	return parts[0], strings.Join(parts[1:], ".")
 }
-
-func IsSystemCollection(ns string) bool {
-	return strings.HasPrefix(ns, "local.") || strings.HasPrefix(ns, "config.") || strings.HasPrefix(ns, "admin.")
-}
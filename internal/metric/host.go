@@ -0,0 +1,30 @@
+package metric
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HostSetting is a kernel setting of the local host checked against the production notes of MongoDB.
+// Value is the raw value as read, and Number its numeric value, NaN if it has none.
+type HostSetting struct {
+	Name      string
+	Value     string
+	Number    float64
+	Compliant bool
+}
+
+func (m *HostSetting) ToPromMetrics() []prometheus.Metric {
+	raw := map[string]float64{"setting_compliant": 0}
+	if m.Compliant {
+		raw["setting_compliant"] = 1
+	}
+	if !math.IsNaN(m.Number) {
+		raw["setting_value"] = m.Number
+	}
+
+	res := buildPromMetrics(hostMetricPrefix, raw, m.Name)
+	info := map[string]float64{"setting_info": 1}
+	return append(res, buildPromMetrics(hostMetricPrefix, info, m.Name, m.Value)...)
+}
@@ -0,0 +1,146 @@
+package metric
+
+import (
+	"sort"
+	"sync"
+)
+
+// OtherNamespace is the namespace into which the namespaces over the limit of a collector are aggregated.
+const OtherNamespace = "__other__.__other__"
+
+// NamespaceLimiter ranks the namespaces of a collector by their activity. It keeps the activity
+// of the previous collection, so that counters are ranked by their increase since then.
+type NamespaceLimiter struct {
+	lock sync.Mutex
+	prev map[string]float64
+
+	// prevCounters is the counters of every namespace on the previous collection, and otherCounters
+	// the increases accumulated into OtherNamespace so far.
+	prevCounters  map[string]map[string]float64
+	otherCounters map[string]float64
+}
+
+func NewNamespaceLimiter() *NamespaceLimiter {
+	return &NamespaceLimiter{}
+}
+
+// Select returns the limit namespaces with the highest activity, or all of them if limit is 0 or less.
+// If cumulative is set, activity is a counter, and the namespaces are ranked by its increase.
+// A nil limiter ranks the namespaces by their activity alone.
+func (l *NamespaceLimiter) Select(limit int, activity map[string]float64, cumulative bool) map[string]bool {
+	selected := make(map[string]bool, len(activity))
+
+	if limit <= 0 || len(activity) <= limit {
+		for ns := range activity {
+			selected[ns] = true
+		}
+		l.update(activity, cumulative)
+		return selected
+	}
+
+	scores := make(map[string]float64, len(activity))
+	namespaces := make([]string, 0, len(activity))
+
+	prev := l.previous()
+	for ns, v := range activity {
+		score := v
+		// A counter lower than before was reset, so its increase is the current value.
+		if p, ok := prev[ns]; ok && cumulative && v >= p {
+			score = v - p
+		}
+		scores[ns] = score
+		namespaces = append(namespaces, ns)
+	}
+
+	sort.Slice(namespaces, func(i, j int) bool {
+		if scores[namespaces[i]] != scores[namespaces[j]] {
+			return scores[namespaces[i]] > scores[namespaces[j]]
+		}
+		return namespaces[i] < namespaces[j]
+	})
+
+	for _, ns := range namespaces[:limit] {
+		selected[ns] = true
+	}
+	l.update(activity, cumulative)
+
+	return selected
+}
+
+func (l *NamespaceLimiter) previous() map[string]float64 {
+	if l == nil {
+		return nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.prev
+}
+
+func (l *NamespaceLimiter) update(activity map[string]float64, cumulative bool) {
+	if l == nil || !cumulative {
+		return
+	}
+
+	prev := make(map[string]float64, len(activity))
+	for ns, v := range activity {
+		prev[ns] = v
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.prev = prev
+}
+
+// AggregateCounters returns the counters of OtherNamespace, into which the counters of the namespaces
+// which are not selected are aggregated. counters is the counters of every namespace by their names.
+// Only the increase of a namespace since the previous collection is added, so that the counters of
+// OtherNamespace do not jump or decrease when a namespace moves in or out of the selected ones.
+// A nil limiter sums the current values of the namespaces which are not selected.
+func (l *NamespaceLimiter) AggregateCounters(counters map[string]map[string]float64, selected map[string]bool) map[string]float64 {
+	if l == nil {
+		other := make(map[string]float64)
+		for ns, values := range counters {
+			if selected[ns] {
+				continue
+			}
+			for name, v := range values {
+				other[name] += v
+			}
+		}
+		return other
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.otherCounters == nil {
+		l.otherCounters = make(map[string]float64)
+	}
+
+	for ns, values := range counters {
+		if selected[ns] {
+			continue
+		}
+		prev := l.prevCounters[ns]
+		for name, v := range values {
+			increase := v
+			// A counter lower than before was reset, so its increase is the current value.
+			if p, ok := prev[name]; ok && v >= p {
+				increase = v - p
+			}
+			l.otherCounters[name] += increase
+		}
+	}
+
+	l.prevCounters = counters
+
+	other := make(map[string]float64, len(l.otherCounters))
+	for name, v := range l.otherCounters {
+		other[name] = v
+	}
+
+	return other
+}
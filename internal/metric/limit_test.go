@@ -0,0 +1,217 @@
+package metric
+
+import (
+	"reflect"
+	"testing"
+)
+
+func namespaceSet(namespaces ...string) map[string]bool {
+	set := make(map[string]bool, len(namespaces))
+	for _, ns := range namespaces {
+		set[ns] = true
+	}
+
+	return set
+}
+
+func TestNamespaceLimiterSelect(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		prev       map[string]float64
+		activity   map[string]float64
+		cumulative bool
+		want       map[string]bool
+	}{
+		{
+			name:     "no limit",
+			limit:    0,
+			activity: map[string]float64{"db.a": 1, "db.b": 2, "db.c": 3},
+			want:     namespaceSet("db.a", "db.b", "db.c"),
+		},
+		{
+			name:     "under the limit",
+			limit:    5,
+			activity: map[string]float64{"db.a": 1, "db.b": 2},
+			want:     namespaceSet("db.a", "db.b"),
+		},
+		{
+			name:     "highest activity",
+			limit:    2,
+			activity: map[string]float64{"db.a": 1, "db.b": 3, "db.c": 2},
+			want:     namespaceSet("db.b", "db.c"),
+		},
+		{
+			name:     "ties are broken by name",
+			limit:    2,
+			activity: map[string]float64{"db.c": 1, "db.b": 1, "db.a": 1},
+			want:     namespaceSet("db.a", "db.b"),
+		},
+		{
+			name:     "ties after the highest activity",
+			limit:    2,
+			activity: map[string]float64{"db.c": 1, "db.b": 1, "db.a": 1, "db.d": 5},
+			want:     namespaceSet("db.a", "db.d"),
+		},
+		{
+			name:       "counters on the first collection",
+			limit:      1,
+			activity:   map[string]float64{"db.a": 10, "db.b": 5},
+			cumulative: true,
+			want:       namespaceSet("db.a"),
+		},
+		{
+			name:       "counters ranked by their increase",
+			limit:      1,
+			prev:       map[string]float64{"db.a": 10, "db.b": 5},
+			activity:   map[string]float64{"db.a": 11, "db.b": 8},
+			cumulative: true,
+			want:       namespaceSet("db.b"),
+		},
+		{
+			name:       "reset counter ranked by its value",
+			limit:      1,
+			prev:       map[string]float64{"db.a": 10, "db.b": 50},
+			activity:   map[string]float64{"db.a": 12, "db.b": 4},
+			cumulative: true,
+			want:       namespaceSet("db.b"),
+		},
+		{
+			name:       "new counter ranked by its value",
+			limit:      1,
+			prev:       map[string]float64{"db.a": 10},
+			activity:   map[string]float64{"db.a": 12, "db.b": 3},
+			cumulative: true,
+			want:       namespaceSet("db.b"),
+		},
+		{
+			name:     "gauges are not ranked by their increase",
+			limit:    1,
+			prev:     map[string]float64{"db.a": 10, "db.b": 5},
+			activity: map[string]float64{"db.a": 11, "db.b": 8},
+			want:     namespaceSet("db.a"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewNamespaceLimiter()
+			if tt.prev != nil {
+				l.Select(tt.limit, tt.prev, tt.cumulative)
+			}
+
+			if got := l.Select(tt.limit, tt.activity, tt.cumulative); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Select(%d, %v, %v) = %v, want %v", tt.limit, tt.activity, tt.cumulative, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilNamespaceLimiterSelect(t *testing.T) {
+	var l *NamespaceLimiter
+
+	activity := map[string]float64{"db.a": 10, "db.b": 5, "db.c": 7}
+	want := namespaceSet("db.a", "db.c")
+
+	// The activity is not kept, so counters are ranked by their value.
+	for i := 0; i < 2; i++ {
+		if got := l.Select(2, activity, true); !reflect.DeepEqual(got, want) {
+			t.Errorf("Select(2, %v, true) = %v, want %v", activity, got, want)
+		}
+	}
+}
+
+func TestNamespaceLimiterAggregateCounters(t *testing.T) {
+	type collection struct {
+		counters map[string]map[string]float64
+		selected map[string]bool
+		want     map[string]float64
+	}
+
+	tests := []struct {
+		name        string
+		collections []collection
+	}{
+		{
+			name: "first collection",
+			collections: []collection{
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 10}, "db.b": {"x": 5}, "db.c": {"x": 1}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 6},
+				},
+			},
+		},
+		{
+			name: "namespace moved into the selected ones",
+			collections: []collection{
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 10}, "db.b": {"x": 5}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 5},
+				},
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 11}, "db.b": {"x": 50}},
+					selected: namespaceSet("db.b"),
+					want:     map[string]float64{"x": 6},
+				},
+			},
+		},
+		{
+			name: "namespace moved out of the selected ones",
+			collections: []collection{
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 10}, "db.b": {"x": 5}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 5},
+				},
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 12}, "db.b": {"x": 20}},
+					selected: namespaceSet("db.b"),
+					want:     map[string]float64{"x": 7},
+				},
+			},
+		},
+		{
+			name: "reset counter",
+			collections: []collection{
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 10}, "db.b": {"x": 5}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 5},
+				},
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 12}, "db.b": {"x": 2}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 7},
+				},
+			},
+		},
+		{
+			name: "dropped namespace",
+			collections: []collection{
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 10}, "db.b": {"x": 5}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 5},
+				},
+				{
+					counters: map[string]map[string]float64{"db.a": {"x": 12}},
+					selected: namespaceSet("db.a"),
+					want:     map[string]float64{"x": 5},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewNamespaceLimiter()
+			for i, c := range tt.collections {
+				if got := l.AggregateCounters(c.counters, c.selected); !reflect.DeepEqual(got, c.want) {
+					t.Errorf("collection %d: AggregateCounters() = %v, want %v", i, got, c.want)
+				}
+			}
+		})
+	}
+}
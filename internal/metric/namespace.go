@@ -0,0 +1,88 @@
+package metric
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var (
+	// DefaultExcludeDatabases excludes the system databases.
+	DefaultExcludeDatabases = []string{`^(admin|local|config)$`}
+	// DefaultExcludeCollections excludes the system collections from the collectors of operations
+	// and chunks, currentopmetrics and shardstats. The other collectors exclude no collection by default.
+	DefaultExcludeCollections = []string{`^system\.(buckets|profile|js|views)$`}
+)
+
+// NamespaceFilter selects the namespaces to collect by regular expressions of the database and
+// the collection names. A namespace is selected if its database matches one of the include patterns
+// of databases, or there are none, and matches none of the exclude patterns. So is its collection.
+type NamespaceFilter struct {
+	includeDatabases   []*regexp.Regexp
+	excludeDatabases   []*regexp.Regexp
+	includeCollections []*regexp.Regexp
+	excludeCollections []*regexp.Regexp
+}
+
+// NamespacePatterns is the regular expressions of a NamespaceFilter.
+type NamespacePatterns struct {
+	IncludeDatabases   []string
+	ExcludeDatabases   []string
+	IncludeCollections []string
+	ExcludeCollections []string
+}
+
+// NewNamespaceFilter compiles the patterns. The patterns are not anchored unless they have ^ and $.
+func NewNamespaceFilter(patterns NamespacePatterns) (*NamespaceFilter, error) {
+	f := &NamespaceFilter{}
+
+	for _, p := range []struct {
+		patterns []string
+		regexps  *[]*regexp.Regexp
+	}{
+		{patterns.IncludeDatabases, &f.includeDatabases},
+		{patterns.ExcludeDatabases, &f.excludeDatabases},
+		{patterns.IncludeCollections, &f.includeCollections},
+		{patterns.ExcludeCollections, &f.excludeCollections},
+	} {
+		for _, pattern := range p.patterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+			}
+			*p.regexps = append(*p.regexps, re)
+		}
+	}
+
+	return f, nil
+}
+
+// Match reports whether the namespace of the form <database>.<collection> is selected.
+// An empty namespace is never selected.
+func (f *NamespaceFilter) Match(ns string) bool {
+	if ns == "" {
+		return false
+	}
+
+	db, coll := ParseNamespace(ns)
+
+	return matchPatterns(db, f.includeDatabases, f.excludeDatabases) &&
+		matchPatterns(coll, f.includeCollections, f.excludeCollections)
+}
+
+func matchPatterns(name string, include, exclude []*regexp.Regexp) bool {
+	if len(include) > 0 && !matchAny(name, include) {
+		return false
+	}
+
+	return !matchAny(name, exclude)
+}
+
+func matchAny(name string, regexps []*regexp.Regexp) bool {
+	for _, re := range regexps {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
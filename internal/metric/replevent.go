@@ -0,0 +1,100 @@
+package metric
+
+import (
+	"mobserver/internal/model"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ReplEventSample is the replication state of a member read on a collection.
+type ReplEventSample struct {
+	// RBID is the rollback identifier of serverStatus, which is incremented on every rollback.
+	// It is negative if it is unknown.
+	RBID int64
+	// Term is the election term of replSetGetStatus, which is incremented on every election.
+	Term int64
+	// Primary is the name of the primary member, empty if there is none.
+	Primary string
+	// States is the state of every member by its name.
+	States map[string]model.MongoReplRoleType
+}
+
+// ReplEvents is the number of the replication events observed since the exporter started.
+type ReplEvents struct {
+	Rollbacks float64 `prom:"rollbacks_total"`
+	Elections float64 `prom:"elections_total"`
+	StepDowns float64 `prom:"step_downs_total"`
+	RBID      float64 `prom:"rollback_id"`
+}
+
+func (m *ReplEvents) ToPromMetrics() []prometheus.Metric {
+	rawMetrics := structToMap(m)
+	if m.RBID < 0 {
+		delete(rawMetrics, "rollback_id")
+	}
+	return buildPromMetrics(roleMetricPrefix, rawMetrics)
+}
+
+// ReplEventTracker counts the replication events by comparing the samples of the collections,
+// so that the events are detected through commands, without the files of the local host.
+// The events between two collections are counted on the later one.
+type ReplEventTracker struct {
+	lock sync.Mutex
+	prev *ReplEventSample
+	// primary is the last primary observed, which is kept while there is no primary, e.g. during an election.
+	primary string
+	events  ReplEvents
+}
+
+func NewReplEventTracker() *ReplEventTracker {
+	return &ReplEventTracker{}
+}
+
+// Observe counts the events since the previous sample and returns the events observed so far.
+// A rollback identifier or a term lower than before, e.g. after the member is resynced, is not counted.
+// A step-down is counted when the last primary is observed in a state other than primary, or when
+// another member is the primary. A primary elected again is not counted, and a primary which is
+// unreachable is counted once another member is the primary.
+func (t *ReplEventTracker) Observe(sample ReplEventSample) ReplEvents {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if prev := t.prev; prev != nil {
+		if prev.RBID >= 0 && sample.RBID > prev.RBID {
+			t.events.Rollbacks += float64(sample.RBID - prev.RBID)
+		}
+		if sample.Term > prev.Term {
+			t.events.Elections += float64(sample.Term - prev.Term)
+		}
+	}
+
+	if t.primary != "" && (sample.Primary != "" && sample.Primary != t.primary || steppedDown(sample, t.primary)) {
+		t.events.StepDowns++
+		t.primary = ""
+	}
+	if sample.Primary != "" {
+		t.primary = sample.Primary
+	}
+
+	t.prev = &sample
+	t.events.RBID = float64(sample.RBID)
+
+	return t.events
+}
+
+// steppedDown returns whether the member is observed in a state other than primary. A member which
+// is unreachable may still be the primary in its own partition.
+func steppedDown(sample ReplEventSample, member string) bool {
+	state, ok := sample.States[member]
+	if !ok {
+		return false
+	}
+
+	switch state {
+	case model.REPL_PRIMARY, model.REPL_UNKNOWN, model.REPL_DOWN:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,140 @@
+package metric
+
+import (
+	"mobserver/internal/model"
+	"testing"
+)
+
+// replSample returns a sample of a set of members a, b and c, in which primary is the primary
+// and the others are in the given state, secondary if it is not given.
+func replSample(rbid, term int64, primary string, states map[string]model.MongoReplRoleType) ReplEventSample {
+	sample := ReplEventSample{RBID: rbid, Term: term, Primary: primary, States: make(map[string]model.MongoReplRoleType)}
+	for _, member := range []string{"a", "b", "c"} {
+		sample.States[member] = model.REPL_SECONDARY
+		if member == primary {
+			sample.States[member] = model.REPL_PRIMARY
+		}
+		if state, ok := states[member]; ok {
+			sample.States[member] = state
+		}
+	}
+
+	return sample
+}
+
+func TestReplEventTrackerObserve(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []ReplEventSample
+		want    ReplEvents
+	}{
+		{
+			name:    "first sample",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil)},
+			want:    ReplEvents{RBID: 3},
+		},
+		{
+			name:    "no change",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(3, 5, "a", nil)},
+			want:    ReplEvents{RBID: 3},
+		},
+		{
+			name:    "rollbacks",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(5, 5, "a", nil)},
+			want:    ReplEvents{Rollbacks: 2, RBID: 5},
+		},
+		{
+			name:    "rollback identifier reset",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(1, 5, "a", nil)},
+			want:    ReplEvents{RBID: 1},
+		},
+		{
+			name:    "rollback identifier first known",
+			samples: []ReplEventSample{replSample(-1, 5, "a", nil), replSample(4, 5, "a", nil)},
+			want:    ReplEvents{RBID: 4},
+		},
+		{
+			name:    "primary elected again",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(3, 7, "a", nil)},
+			want:    ReplEvents{Elections: 2, RBID: 3},
+		},
+		{
+			name:    "term reset",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(3, 1, "a", nil)},
+			want:    ReplEvents{RBID: 3},
+		},
+		{
+			name:    "another primary",
+			samples: []ReplEventSample{replSample(3, 5, "a", nil), replSample(3, 6, "b", nil)},
+			want:    ReplEvents{Elections: 1, StepDowns: 1, RBID: 3},
+		},
+		{
+			name: "election in progress",
+			samples: []ReplEventSample{
+				replSample(3, 5, "a", nil),
+				replSample(3, 6, "", nil),
+				replSample(3, 6, "b", nil),
+			},
+			want: ReplEvents{Elections: 1, StepDowns: 1, RBID: 3},
+		},
+		{
+			name: "unreachable primary",
+			samples: []ReplEventSample{
+				replSample(3, 5, "a", nil),
+				replSample(3, 5, "", map[string]model.MongoReplRoleType{"a": model.REPL_DOWN}),
+				replSample(3, 5, "a", nil),
+			},
+			want: ReplEvents{RBID: 3},
+		},
+		{
+			name: "unreachable primary replaced",
+			samples: []ReplEventSample{
+				replSample(3, 5, "a", nil),
+				replSample(3, 5, "", map[string]model.MongoReplRoleType{"a": model.REPL_DOWN}),
+				replSample(3, 6, "b", map[string]model.MongoReplRoleType{"a": model.REPL_DOWN}),
+			},
+			want: ReplEvents{Elections: 1, StepDowns: 1, RBID: 3},
+		},
+		{
+			name: "stepped down and elected again",
+			samples: []ReplEventSample{
+				replSample(3, 5, "a", nil),
+				replSample(3, 5, "", nil),
+				replSample(3, 6, "a", nil),
+			},
+			want: ReplEvents{Elections: 1, StepDowns: 1, RBID: 3},
+		},
+		{
+			name: "stepped down into rollback",
+			samples: []ReplEventSample{
+				replSample(3, 5, "a", nil),
+				replSample(3, 6, "b", map[string]model.MongoReplRoleType{"a": model.REPL_ROLLBACK}),
+				replSample(4, 6, "b", nil),
+			},
+			want: ReplEvents{Rollbacks: 1, Elections: 1, StepDowns: 1, RBID: 4},
+		},
+		{
+			name: "no primary on the first sample",
+			samples: []ReplEventSample{
+				replSample(3, 5, "", nil),
+				replSample(3, 6, "b", nil),
+			},
+			want: ReplEvents{Elections: 1, RBID: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := NewReplEventTracker()
+
+			var got ReplEvents
+			for _, sample := range tt.samples {
+				got = tracker.Observe(sample)
+			}
+
+			if got != tt.want {
+				t.Errorf("Observe() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
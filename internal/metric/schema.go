@@ -13,6 +13,9 @@ const (
 	systemMetricPrefix   = "mongodb_system"
 	shardingMetricPrefix = "mongodb_config"
 	instanceMetricPrefix = "mongodb_instance"
+
+	collectorMetricPrefix = "mobserver_collector"
+	hostMetricPrefix      = "mobserver_host"
 )
 
 type Metric struct {
@@ -98,6 +101,22 @@ var Schema = map[string]map[string]Metric{
 			LabelNames:  []string{"role"},
 			PmValueType: prometheus.GaugeValue,
 		},
+		"rollbacks_total": {
+			Help:        "Number of the rollbacks of the member observed by the increase of its rollback identifier",
+			PmValueType: prometheus.CounterValue,
+		},
+		"elections_total": {
+			Help:        "Number of the elections observed by the increase of the term",
+			PmValueType: prometheus.CounterValue,
+		},
+		"step_downs_total": {
+			Help:        "Number of the step-downs of the primary observed between the collections",
+			PmValueType: prometheus.CounterValue,
+		},
+		"rollback_id": {
+			Help:        "Rollback identifier of the member, which is incremented on every rollback",
+			PmValueType: prometheus.GaugeValue,
+		},
 	},
 
 	// Metadata for oplog metrics
@@ -300,6 +319,138 @@ var Schema = map[string]map[string]Metric{
 			LabelNames:  []string{"database", "collection"},
 			PmValueType: prometheus.GaugeValue,
 		},
+		"rollback_files": {
+			Help:        "Number of the rollback files of the collection",
+			LabelNames:  []string{"database", "collection"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"rollback_size_bytes": {
+			Help:        "Total size of the rollback files of the collection, which is the data dropped by the rollbacks",
+			LabelNames:  []string{"database", "collection"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"rollback_newest_age_seconds": {
+			Help:        "Elapsed seconds since the newest rollback file of the collection was written",
+			LabelNames:  []string{"database", "collection"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"lvm_size_bytes": {
+			Help:        "Size of the logical volume",
+			LabelNames:  []string{"vg", "lv"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"lvm_data_percent": {
+			Help:        "Used percentage of the data of the thin pool, thin volume or snapshot",
+			LabelNames:  []string{"vg", "lv"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"lvm_metadata_percent": {
+			Help:        "Used percentage of the metadata of the thin pool",
+			LabelNames:  []string{"vg", "lv"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"lvm_snapshot_age_seconds": {
+			Help:        "Elapsed seconds since the snapshot of the origin volume was created",
+			LabelNames:  []string{"vg", "lv", "origin"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"snapshot_count": {
+			Help:        "Number of the snapshots in the pool, which is the volume group of LVM, the pool of ZFS or the filesystem of btrfs",
+			LabelNames:  []string{"backend", "pool"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"snapshot_newest_age_seconds": {
+			Help:        "Elapsed seconds since the newest snapshot in the pool was created",
+			LabelNames:  []string{"backend", "pool"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"snapshot_used_bytes": {
+			Help:        "Space consumed by the snapshots in the pool",
+			LabelNames:  []string{"backend", "pool"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"snapshot_pool_free_bytes": {
+			Help:        "Free space of the pool for the snapshots to grow",
+			LabelNames:  []string{"backend", "pool"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"backup_newest_age_seconds": {
+			Help:        "Elapsed seconds since the newest file in the backup directory was modified",
+			LabelNames:  []string{"dir"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"backup_size_bytes": {
+			Help:        "Total size of the files in the backup directory",
+			LabelNames:  []string{"dir"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"backup_files": {
+			Help:        "Number of the files in the backup directory",
+			LabelNames:  []string{"dir"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"disk_total_bytes": {
+			Help:        "Size of the filesystem of the directory of MongoDB by the kind (dbpath, journal, log, backup)",
+			LabelNames:  []string{"kind", "path"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"disk_free_bytes": {
+			Help:        "Free space of the filesystem of the directory of MongoDB available to unprivileged users",
+			LabelNames:  []string{"kind", "path"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"disk_inodes_total": {
+			Help:        "Number of the inodes of the filesystem of the directory of MongoDB",
+			LabelNames:  []string{"kind", "path"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"disk_inodes_free": {
+			Help:        "Number of the free inodes of the filesystem of the directory of MongoDB",
+			LabelNames:  []string{"kind", "path"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"data_files_bytes": {
+			Help:        "Total size of the files under the dbPath of MongoDB",
+			LabelNames:  []string{"path"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_resident_memory_bytes": {
+			Help:        "Resident memory size of the MongoDB process",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_open_fds": {
+			Help:        "Number of the open file descriptors of the MongoDB process",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_max_fds": {
+			Help:        "Soft limit of the open file descriptors (RLIMIT_NOFILE) of the MongoDB process",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_threads": {
+			Help:        "Number of the threads of the MongoDB process",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_voluntary_context_switches_total": {
+			Help:        "Number of the voluntary context switches of the MongoDB process",
+			PmValueType: prometheus.CounterValue,
+		},
+		"process_nonvoluntary_context_switches_total": {
+			Help:        "Number of the involuntary context switches of the MongoDB process",
+			PmValueType: prometheus.CounterValue,
+		},
+		"process_cgroup_memory_limit_bytes": {
+			Help:        "Memory limit of the cgroup of the MongoDB process",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"process_cgroup_memory_usage_bytes": {
+			Help:        "Memory usage of the cgroup of the MongoDB process, including the page cache",
+			PmValueType: prometheus.GaugeValue,
+		},
+		"backup_marker_present": {
+			Help:        "The completion marker of the newest backup exists or not",
+			LabelNames:  []string{"dir", "marker"},
+			PmValueType: prometheus.GaugeValue,
+		},
 	},
 
 	// Metadata for instance metrics
@@ -309,4 +460,47 @@ var Schema = map[string]map[string]Metric{
 			PmValueType: prometheus.GaugeValue,
 		},
 	},
+
+	// Metadata for the settings of the local host
+	hostMetricPrefix: {
+		"setting_compliant": {
+			Help:        "The kernel setting of the host complies with the production notes of MongoDB or not",
+			LabelNames:  []string{"setting"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"setting_value": {
+			Help:        "Numeric value of the kernel setting of the host",
+			LabelNames:  []string{"setting"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"setting_info": {
+			Help:        "Raw value of the kernel setting of the host in the label value",
+			LabelNames:  []string{"setting", "value"},
+			PmValueType: prometheus.GaugeValue,
+		},
+	},
+
+	// Metadata for the metrics of mobserver collectors
+	collectorMetricPrefix: {
+		"staleness_seconds": {
+			Help:        "Elapsed seconds since the last completed collection in background mode",
+			LabelNames:  []string{"collector"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"duration_seconds": {
+			Help:        "Duration of the last collection in seconds",
+			LabelNames:  []string{"collector"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"success": {
+			Help:        "Whether the last collection succeeded",
+			LabelNames:  []string{"collector"},
+			PmValueType: prometheus.GaugeValue,
+		},
+		"namespaces_dropped": {
+			Help:        "Number of namespaces over the limit of the collector, aggregated into the __other__ namespace",
+			LabelNames:  []string{"collector"},
+			PmValueType: prometheus.GaugeValue,
+		},
+	},
 }
@@ -1,7 +1,7 @@
 package metric
 
 import (
-	"strings"
+	"math"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -10,21 +10,177 @@ func SnapshotStatusToPromMetrics(alloced float64) []prometheus.Metric {
 	return buildPromMetrics(systemMetricPrefix, map[string]float64{"snapshot_allocation": alloced})
 }
 
-func RollbackStatusToPromMetrics(ri map[string]bool) []prometheus.Metric {
+// LogicalVolume is the status of a logical volume. The percentages are NaN if they do not apply to it,
+// and SnapshotAge is NaN unless it is a snapshot of Origin.
+type LogicalVolume struct {
+	VG              string
+	LV              string
+	Origin          string
+	Size            float64
+	DataPercent     float64
+	MetadataPercent float64
+	SnapshotAge     float64
+}
+
+func (m *LogicalVolume) ToPromMetrics() []prometheus.Metric {
+	raw := map[string]float64{"lvm_size_bytes": m.Size}
+	if !math.IsNaN(m.DataPercent) {
+		raw["lvm_data_percent"] = m.DataPercent
+	}
+	if !math.IsNaN(m.MetadataPercent) {
+		raw["lvm_metadata_percent"] = m.MetadataPercent
+	}
+
+	res := buildPromMetrics(systemMetricPrefix, raw, m.VG, m.LV)
+
+	if !math.IsNaN(m.SnapshotAge) {
+		snapshot := map[string]float64{"lvm_snapshot_age_seconds": m.SnapshotAge}
+		res = append(res, buildPromMetrics(systemMetricPrefix, snapshot, m.VG, m.LV, m.Origin)...)
+	}
+
+	return res
+}
+
+// SnapshotPool is the snapshots of a pool of a snapshot backend. NewestAge is NaN if there is no snapshot,
+// and Used or Free is NaN if the backend cannot tell it.
+type SnapshotPool struct {
+	Backend   string
+	Pool      string
+	Count     float64
+	NewestAge float64
+	Used      float64
+	Free      float64
+}
+
+func (m *SnapshotPool) ToPromMetrics() []prometheus.Metric {
+	raw := map[string]float64{"snapshot_count": m.Count}
+	if !math.IsNaN(m.NewestAge) {
+		raw["snapshot_newest_age_seconds"] = m.NewestAge
+	}
+	if !math.IsNaN(m.Used) {
+		raw["snapshot_used_bytes"] = m.Used
+	}
+	if !math.IsNaN(m.Free) {
+		raw["snapshot_pool_free_bytes"] = m.Free
+	}
+
+	return buildPromMetrics(systemMetricPrefix, raw, m.Backend, m.Pool)
+}
+
+// AddSnapshot counts a snapshot of the pool created age seconds ago.
+func (m *SnapshotPool) AddSnapshot(age float64) {
+	m.Count++
+	if math.IsNaN(m.NewestAge) || age < m.NewestAge {
+		m.NewestAge = age
+	}
+}
+
+// RollbackFiles is the rollback files of a namespace. NewestAge is NaN if there is no file.
+type RollbackFiles struct {
+	Files     float64 `prom:"rollback_files"`
+	Size      float64 `prom:"rollback_size_bytes"`
+	NewestAge float64 `prom:"rollback_newest_age_seconds"`
+}
+
+// AddFile counts a rollback file of size bytes modified age seconds ago.
+func (m *RollbackFiles) AddFile(size, age float64) {
+	m.Files++
+	m.Size += size
+	if math.IsNaN(m.NewestAge) || age < m.NewestAge {
+		m.NewestAge = age
+	}
+}
+
+func RollbackStatusToPromMetrics(ri map[string]*RollbackFiles) []prometheus.Metric {
 	res := []prometheus.Metric{}
-	for ns, v := range ri {
-		if !v {
-			continue
+	for ns, files := range ri {
+		db, coll := ParseNamespace(ns)
+
+		raw := structToMap(files)
+		raw["rollback_directory"] = 1
+		if math.IsNaN(files.NewestAge) {
+			delete(raw, "rollback_newest_age_seconds")
 		}
+		res = append(res, buildPromMetrics(systemMetricPrefix, raw, db, coll)...)
+	}
+	return res
+}
+
+// BackupStatus is the files of the backup directory. NewestAge is NaN if there is no file.
+type BackupStatus struct {
+	Dir       string
+	NewestAge float64 `prom:"backup_newest_age_seconds"`
+	Size      float64 `prom:"backup_size_bytes"`
+	Files     float64 `prom:"backup_files"`
+
+	// Marker is the name of the completion marker, which is not checked if it is empty.
+	Marker        string
+	MarkerPresent bool
+}
 
-		nsLabels := strings.Split(ns, ".")
+func (m *BackupStatus) ToPromMetrics() []prometheus.Metric {
+	rawMetrics := structToMap(m)
+	if math.IsNaN(m.NewestAge) {
+		delete(rawMetrics, "backup_newest_age_seconds")
+	}
 
-		raw := map[string]float64{"rollback_directory": 1}
-		res = append(res, buildPromMetrics(systemMetricPrefix, raw, nsLabels...)...)
+	res := buildPromMetrics(systemMetricPrefix, rawMetrics, m.Dir)
+
+	if m.Marker != "" {
+		marker := map[string]float64{"backup_marker_present": 0}
+		if m.MarkerPresent {
+			marker["backup_marker_present"] = 1
+		}
+		res = append(res, buildPromMetrics(systemMetricPrefix, marker, m.Dir, m.Marker)...)
 	}
+
 	return res
 }
 
+// DiskUsage is the usage of the filesystem of a directory of MongoDB.
+type DiskUsage struct {
+	// Kind is the use of the directory: dbpath, journal, log or backup.
+	Kind       string
+	Path       string
+	Total      float64 `prom:"disk_total_bytes"`
+	Free       float64 `prom:"disk_free_bytes"`
+	Inodes     float64 `prom:"disk_inodes_total"`
+	InodesFree float64 `prom:"disk_inodes_free"`
+}
+
+func (m *DiskUsage) ToPromMetrics() []prometheus.Metric {
+	rawMetrics := structToMap(m)
+	return buildPromMetrics(systemMetricPrefix, rawMetrics, m.Kind, m.Path)
+}
+
+func DataFilesToPromMetrics(dbPath string, size float64) []prometheus.Metric {
+	return buildPromMetrics(systemMetricPrefix, map[string]float64{"data_files_bytes": size}, dbPath)
+}
+
+// ProcessStatus is the status of the MongoDB process on the local host. The values which cannot be read,
+// such as the file descriptors of a process of another user or the limit of an unlimited cgroup, are NaN.
+type ProcessStatus struct {
+	ResidentMemory           float64 `prom:"process_resident_memory_bytes"`
+	OpenFDs                  float64 `prom:"process_open_fds"`
+	MaxFDs                   float64 `prom:"process_max_fds"`
+	Threads                  float64 `prom:"process_threads"`
+	VoluntaryCtxtSwitches    float64 `prom:"process_voluntary_context_switches_total"`
+	NonvoluntaryCtxtSwitches float64 `prom:"process_nonvoluntary_context_switches_total"`
+	CgroupMemoryLimit        float64 `prom:"process_cgroup_memory_limit_bytes"`
+	CgroupMemoryUsage        float64 `prom:"process_cgroup_memory_usage_bytes"`
+}
+
+func (m *ProcessStatus) ToPromMetrics() []prometheus.Metric {
+	rawMetrics := structToMap(m)
+	for k, v := range rawMetrics {
+		if math.IsNaN(v) {
+			delete(rawMetrics, k)
+		}
+	}
+
+	return buildPromMetrics(systemMetricPrefix, rawMetrics)
+}
+
 type SystemStatus struct {
 	Snapshot    float64 `prom:"snapshot_allocation"`
 	RollbackDir float64 `prom:"rollback_directory"`
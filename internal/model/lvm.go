@@ -0,0 +1,31 @@
+package model
+
+// LVSReportDoc is the output of lvs --reportformat json. Every value is a string,
+// and the ones which do not apply to a logical volume are empty.
+type LVSReportDoc struct {
+	Report []struct {
+		LV []LogicalVolumeDoc `json:"lv"`
+	} `json:"report"`
+}
+
+// LogicalVolumeDoc is a logical volume of the lvs report
+type LogicalVolumeDoc struct {
+	VGName string `json:"vg_name"`
+	LVName string `json:"lv_name"`
+	// free space of the volume group in bytes
+	VGFree string `json:"vg_free"`
+
+	// size in bytes with --units b --nosuffix
+	Size            string `json:"lv_size"`
+	DataPercent     string `json:"data_percent"`
+	MetadataPercent string `json:"metadata_percent"`
+
+	// origin is set only for snapshots
+	Origin string `json:"origin"`
+	// creation time in the form 2006-01-02 15:04:05 -0700
+	Time string `json:"lv_time"`
+
+	// device numbers of the active volume, -1 if it is inactive
+	KernelMajor string `json:"lv_kernel_major"`
+	KernelMinor string `json:"lv_kernel_minor"`
+}
@@ -1,5 +1,7 @@
 package model
 
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
 // HelloDoc is a response model from hello command
 type HelloDoc struct {
 	// replica set name
@@ -29,6 +31,11 @@ type CmdLineOptsDoc struct {
 			DBPath string `bson:"dbPath"`
 		} `bson:"storage"`
 
+		SystemLog struct {
+			Destination string `bson:"destination"`
+			Path        string `bson:"path"`
+		} `bson:"systemLog"`
+
 		Replication struct {
 			ReplSetName string `bson:"replSetName"`
 		} `bson:"replication"`
@@ -38,3 +45,57 @@ type CmdLineOptsDoc struct {
 		} `bson:"sharding"`
 	} `bson:"parsed"`
 }
+
+// ServerStatusDoc is a response model from serverStatus command
+type ServerStatusDoc struct {
+	Version string `bson:"version"`
+	Pid     int64  `bson:"pid"`
+
+	Repl struct {
+		// rollback identifier, which is incremented on every rollback of this member,
+		// nil unless the member replicates data
+		RBID *int64 `bson:"rbid"`
+	} `bson:"repl"`
+}
+
+// ShardingStateDoc is a response model from shardingState command
+type ShardingStateDoc struct {
+	Enabled   bool               `bson:"enabled"`
+	ShardName string             `bson:"shardName"`
+	ClusterID primitive.ObjectID `bson:"clusterId"`
+}
+
+// ConfigVersionDoc is a document of config.version collection
+type ConfigVersionDoc struct {
+	ClusterID primitive.ObjectID `bson:"clusterId"`
+}
+
+// ConnectionStatusDoc is a response model from connectionStatus command with showPrivileges
+type ConnectionStatusDoc struct {
+	AuthInfo struct {
+		AuthenticatedUsers []struct {
+			User string `bson:"user"`
+			DB   string `bson:"db"`
+		} `bson:"authenticatedUsers"`
+
+		AuthenticatedUserRoles []struct {
+			Role string `bson:"role"`
+			DB   string `bson:"db"`
+		} `bson:"authenticatedUserRoles"`
+
+		AuthenticatedUserPrivileges []PrivilegeDoc `bson:"authenticatedUserPrivileges"`
+	} `bson:"authInfo"`
+}
+
+// PrivilegeDoc is a privilege granted to the authenticated users
+type PrivilegeDoc struct {
+	Resource struct {
+		// empty db or collection means any database or collection
+		DB          string `bson:"db"`
+		Collection  string `bson:"collection"`
+		Cluster     bool   `bson:"cluster"`
+		AnyResource bool   `bson:"anyResource"`
+	} `bson:"resource"`
+
+	Actions []string `bson:"actions"`
+}
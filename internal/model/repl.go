@@ -6,6 +6,7 @@ import (
 
 type ReplSetGetStatusDoc struct {
 	Date    time.Time            `bson:"date"`
+	Term    int64                `bson:"term"`
 	MyState MongoReplRoleType    `bson:"myState"`
 	Members []*RSStatusMemberDoc `bson:"members"`
 	Set     string               `bson:"set"`
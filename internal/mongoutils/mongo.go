@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"mobserver/internal/model"
+	"regexp"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,6 +20,15 @@ type ConnectionOpts struct {
 	Password         string
 	DirectConnect    bool
 	ConnectTimeoutMS int64
+
+	// Hosts overrides the hosts of URI if it is not empty.
+	Hosts []string
+
+	// TLS overrides the TLS settings of URI if it is set.
+	TLS *TLSOpts
+	// AuthMechanism overrides the authentication mechanism of URI if it is set.
+	// MONGODB-X509 and PLAIN authenticate against the $external database.
+	AuthMechanism string
 }
 
 func Connect(ctx context.Context, opts *ConnectionOpts) (*mongo.Client, error) {
@@ -30,6 +40,36 @@ func Connect(ctx context.Context, opts *ConnectionOpts) (*mongo.Client, error) {
 		})
 	}
 
+	if opts.AuthMechanism != "" {
+		cred := options.Credential{}
+		if clientOpts.Auth != nil {
+			cred = *clientOpts.Auth
+		}
+		cred.AuthMechanism = opts.AuthMechanism
+		if opts.AuthMechanism == "MONGODB-X509" || opts.AuthMechanism == "PLAIN" {
+			cred.AuthSource = "$external"
+		}
+		if opts.AuthMechanism == "MONGODB-X509" {
+			// The user is taken from the subject of the client certificate.
+			cred.Password = ""
+			cred.PasswordSet = false
+		}
+		clientOpts.SetAuth(cred)
+	}
+
+	if opts.TLS != nil {
+		// The TLS options of the URI are kept unless the flags override them.
+		tlsConfig, err := opts.TLS.MergeConfig(clientOpts.TLSConfig)
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS options: %w", err)
+		}
+		clientOpts.SetTLSConfig(tlsConfig)
+	}
+
+	if len(opts.Hosts) > 0 {
+		clientOpts.SetHosts(opts.Hosts)
+	}
+
 	clientOpts.SetDirect(opts.DirectConnect)
 	clientOpts.SetAppName("mobserver")
 
@@ -54,11 +94,85 @@ func Connect(ctx context.Context, opts *ConnectionOpts) (*mongo.Client, error) {
 	return client, nil
 }
 
+var uriPassword = regexp.MustCompile(`(mongodb(?:\+srv)?://[^:@/\s]*):([^@/\s]*)@`)
+
+// RedactURI returns the URI with its password replaced, to be logged. So are the passwords
+// of the URIs within a text, e.g. an error message.
+func RedactURI(uri string) string {
+	return uriPassword.ReplaceAllString(uri, "${1}:xxxxx@")
+}
+
+// URIPassword returns the password of the URI as it is escaped in the URI, the one RedactURI replaces.
+func URIPassword(uri string) string {
+	m := uriPassword.FindStringSubmatch(uri)
+	if m == nil {
+		return ""
+	}
+
+	return m[2]
+}
+
+// MaxTime returns the time left until the deadline of ctx, which is used as maxTimeMS of
+// the commands so that the server gives them up when the caller does.
+func MaxTime(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+
+	maxTime := time.Until(deadline)
+	if maxTime < time.Millisecond {
+		// maxTimeMS of 0 means no limit.
+		maxTime = time.Millisecond
+	}
+
+	return maxTime, true
+}
+
+// WithMaxTime appends maxTimeMS to cmd if ctx has a deadline.
+func WithMaxTime(ctx context.Context, cmd bson.D) bson.D {
+	if maxTime, ok := MaxTime(ctx); ok {
+		return append(cmd, bson.E{Key: "maxTimeMS", Value: maxTime.Milliseconds()})
+	}
+
+	return cmd
+}
+
+// FindOptions returns find options limited by the deadline of ctx.
+func FindOptions(ctx context.Context) *options.FindOptions {
+	opts := options.Find()
+	if maxTime, ok := MaxTime(ctx); ok {
+		opts.SetMaxTime(maxTime)
+	}
+
+	return opts
+}
+
+// FindOneOptions returns findOne options limited by the deadline of ctx.
+func FindOneOptions(ctx context.Context) *options.FindOneOptions {
+	opts := options.FindOne()
+	if maxTime, ok := MaxTime(ctx); ok {
+		opts.SetMaxTime(maxTime)
+	}
+
+	return opts
+}
+
+// AggregateOptions returns aggregate options limited by the deadline of ctx.
+func AggregateOptions(ctx context.Context) *options.AggregateOptions {
+	opts := options.Aggregate()
+	if maxTime, ok := MaxTime(ctx); ok {
+		opts.SetMaxTime(maxTime)
+	}
+
+	return opts
+}
+
 func GetHello(ctx context.Context, client *mongo.Client) (*model.HelloDoc, error) {
 	var result model.HelloDoc
 	cmd := bson.D{{Key: "hello", Value: 1}}
 
-	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run hello command: %w", err)
 	}
 
@@ -69,18 +183,68 @@ func GetCmdLineOpts(ctx context.Context, client *mongo.Client) (*model.CmdLineOp
 	var result model.CmdLineOptsDoc
 	cmd := bson.D{{Key: "getCmdLineOpts", Value: 1}}
 
-	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run getCmdLineOpts command: %w", err)
 	}
 
 	return &result, nil
 }
 
+// GetServerStatus runs serverStatus without the large sections which are not read.
+func GetServerStatus(ctx context.Context, client *mongo.Client) (*model.ServerStatusDoc, error) {
+	var result model.ServerStatusDoc
+	cmd := bson.D{
+		{Key: "serverStatus", Value: 1},
+		{Key: "locks", Value: 0},
+		{Key: "metrics", Value: 0},
+		{Key: "tcmalloc", Value: 0},
+		{Key: "wiredTiger", Value: 0},
+	}
+
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot run serverStatus command: %w", err)
+	}
+
+	return &result, nil
+}
+
+func GetConnectionStatus(ctx context.Context, client *mongo.Client) (*model.ConnectionStatusDoc, error) {
+	var result model.ConnectionStatusDoc
+	cmd := bson.D{{Key: "connectionStatus", Value: 1}, {Key: "showPrivileges", Value: true}}
+
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot run connectionStatus command: %w", err)
+	}
+
+	return &result, nil
+}
+
+func GetShardingState(ctx context.Context, client *mongo.Client) (*model.ShardingStateDoc, error) {
+	var result model.ShardingStateDoc
+	cmd := bson.D{{Key: "shardingState", Value: 1}}
+
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot run shardingState command: %w", err)
+	}
+
+	return &result, nil
+}
+
+func GetConfigVersion(ctx context.Context, client *mongo.Client) (*model.ConfigVersionDoc, error) {
+	var result model.ConfigVersionDoc
+
+	if err := client.Database("config").Collection("version").FindOne(ctx, bson.D{}, FindOneOptions(ctx)).Decode(&result); err != nil {
+		return nil, fmt.Errorf("cannot find config.version: %w", err)
+	}
+
+	return &result, nil
+}
+
 func GetReplStatus(ctx context.Context, client *mongo.Client) (*model.ReplSetGetStatusDoc, error) {
 	var result model.ReplSetGetStatusDoc
 	cmd := bson.D{{Key: "replSetGetStatus", Value: 1}, {Key: "initialSync", Value: 1}}
 
-	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run replSetGetStatus command: %w", err)
 	}
 
@@ -91,7 +255,7 @@ func GetReplConfig(ctx context.Context, client *mongo.Client) (*model.ReplSetGet
 	var result model.ReplSetGetConfigDoc
 	cmd := bson.D{{Key: "replSetGetConfig", Value: 1}}
 
-	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run replSetGetConfig command: %w", err)
 	}
 
@@ -102,7 +266,7 @@ func GetDatabases(ctx context.Context, client *mongo.Client) ([]string, error) {
 	var result model.ListDatabasesDoc
 	cmd := bson.D{{Key: "listDatabases", Value: 1}}
 
-	if err := client.Database("admin").RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database("admin").RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run listDatabases command: %w", err)
 	}
 
@@ -118,7 +282,7 @@ func GetCollections(ctx context.Context, client *mongo.Client, db string) ([]mod
 	var result model.ListCollectionsDoc
 	cmd := bson.D{{Key: "listCollections", Value: 1}}
 
-	if err := client.Database(db).RunCommand(ctx, cmd).Decode(&result); err != nil {
+	if err := client.Database(db).RunCommand(ctx, WithMaxTime(ctx, cmd)).Decode(&result); err != nil {
 		return nil, fmt.Errorf("cannot run listCollections command: %w", err)
 	}
 
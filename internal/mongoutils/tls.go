@@ -0,0 +1,216 @@
+package mongoutils
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/youmark/pkcs8"
+)
+
+// TLSOpts is the TLS settings of the connection. The files are in PEM format.
+type TLSOpts struct {
+	// CAFile is the certificate authorities to verify the server with, the system ones if it is empty.
+	CAFile string
+	// CertFile is the client certificate, and KeyFile its private key, which may also be in CertFile.
+	CertFile string
+	KeyFile  string
+	// KeyPassword decrypts the private key if it is encrypted.
+	KeyPassword string
+	// AllowInvalidHostnames skips the verification of the hostname of the server certificate,
+	// but the certificate is still verified against the certificate authorities.
+	AllowInvalidHostnames bool
+}
+
+// Config reads the files and returns the TLS configuration.
+func (o *TLSOpts) Config() (*tls.Config, error) {
+	return o.MergeConfig(nil)
+}
+
+// MergeConfig reads the files and returns a copy of base with the settings which are set in o.
+// The other settings of base, e.g. those of the TLS options of the URI, are kept.
+func (o *TLSOpts) MergeConfig(base *tls.Config) (*tls.Config, error) {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if base != nil {
+		cfg = base.Clone()
+	}
+
+	if o.CAFile != "" {
+		certs, err := ReadCertificates(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.RootCAs = x509.NewCertPool()
+		for _, cert := range certs {
+			cfg.RootCAs.AddCert(cert)
+		}
+	}
+
+	if o.CertFile != "" {
+		cert, err := o.clientCertificate()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+
+	if o.AllowInvalidHostnames {
+		// Verify the chain in VerifyPeerCertificate instead, without the hostname.
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, cfg.RootCAs)
+		}
+	}
+
+	return cfg, nil
+}
+
+func (o *TLSOpts) clientCertificate() (*tls.Certificate, error) {
+	certs, err := ReadCertificates(o.CertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	keyFile := o.KeyFile
+	if keyFile == "" {
+		keyFile = o.CertFile
+	}
+
+	key, err := readPrivateKey(keyFile, o.KeyPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &tls.Certificate{PrivateKey: key, Leaf: certs[0]}
+	for _, c := range certs {
+		cert.Certificate = append(cert.Certificate, c.Raw)
+	}
+
+	return cert, nil
+}
+
+// ReadCertificates returns the certificates of the PEM file.
+func ReadCertificates(path string) ([]*x509.Certificate, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read certificate file: %w", err)
+	}
+
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse certificate in %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found in %s", path)
+	}
+
+	return certs, nil
+}
+
+// readPrivateKey returns the first private key of the PEM file, decrypting it with password
+// if it is an encrypted PKCS#8 key or a legacy encrypted PEM block.
+func readPrivateKey(path, password string) (crypto.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read private key file: %w", err)
+	}
+
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in %s", path)
+		}
+
+		switch {
+		case block.Type == "ENCRYPTED PRIVATE KEY":
+			if password == "" {
+				return nil, fmt.Errorf("private key in %s is encrypted, but no password is given", path)
+			}
+			key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, []byte(password))
+			if err != nil {
+				return nil, fmt.Errorf("cannot decrypt private key in %s: %w", path, err)
+			}
+			return key, nil
+		case block.Type == "PRIVATE KEY" || block.Type == "RSA PRIVATE KEY" || block.Type == "EC PRIVATE KEY":
+			der := block.Bytes
+			//nolint:staticcheck // Legacy encrypted PEM blocks are still produced by openssl and MongoDB tooling.
+			if x509.IsEncryptedPEMBlock(block) {
+				if password == "" {
+					return nil, fmt.Errorf("private key in %s is encrypted, but no password is given", path)
+				}
+				//nolint:staticcheck
+				der, err = x509.DecryptPEMBlock(block, []byte(password))
+				if err != nil {
+					return nil, fmt.Errorf("cannot decrypt private key in %s: %w", path, err)
+				}
+			}
+			key, err := parsePrivateKey(der)
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse private key in %s: %w", path, err)
+			}
+			return key, nil
+		}
+	}
+}
+
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+
+	return nil, errors.New("unknown private key type")
+}
+
+// verifyChain verifies the server certificate against roots, the system ones if it is nil, ignoring its hostname.
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("no server certificate")
+	}
+
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("cannot parse server certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+	})
+
+	return err
+}
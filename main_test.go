@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCollectorArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "collector flag",
+			args: []string{"--collector.topmetrics"},
+			want: []string{"--collector=topmetrics"},
+		},
+		{
+			name: "repeated collector flags",
+			args: []string{"--collector.topmetrics", "--collector.shardstats", "--collector=oplogstats"},
+			want: []string{"--collector=topmetrics", "--collector=shardstats", "--collector=oplogstats"},
+		},
+		{
+			name: "explicit true",
+			args: []string{"--collector.topmetrics=true"},
+			want: []string{"--collector=topmetrics"},
+		},
+		{
+			name: "explicit false",
+			args: []string{"--collector.topmetrics=false", "--collector.shardstats"},
+			want: []string{"--collector=shardstats"},
+		},
+		{
+			name: "invalid value is left for the parser",
+			args: []string{"--collector.topmetrics=yes"},
+			want: []string{"--collector.topmetrics=yes"},
+		},
+		{
+			name: "flags of the collection are kept",
+			args: []string{"--collector.background", "--collector.interval=1m", "--collector.intervals", "shardstats=5m"},
+			want: []string{"--collector.background", "--collector.interval=1m", "--collector.intervals", "shardstats=5m"},
+		},
+		{
+			name: "unknown collector is left for the parser",
+			args: []string{"--collector.unknown"},
+			want: []string{"--collector.unknown"},
+		},
+		{
+			name: "other flags are kept in order",
+			args: []string{"--log.level=debug", "--collector.topmetrics", "check"},
+			want: []string{"--log.level=debug", "--collector=topmetrics", "check"},
+		},
+		{
+			name: "arguments after -- are kept",
+			args: []string{"--collector.topmetrics", "--", "--collector.shardstats"},
+			want: []string{"--collector=topmetrics", "--", "--collector.shardstats"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := collectorArgs(tt.args); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collectorArgs(%q) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}